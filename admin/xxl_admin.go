@@ -16,6 +16,9 @@ type XxlAdminServer struct {
 	Registry    *transport.RegistryParam
 	BeatTime    time.Duration
 	executor    *executor.Executor
+
+	registerLock sync.Mutex //注册、移除注册及暂停状态的修改互斥，避免暂停后仍被注册
+	paused       bool       //暂停注册
 }
 
 const (
@@ -55,17 +58,23 @@ func NewAdminServer(addresses []string, timeout, beatTime time.Duration, executo
 	return s
 }
 
+// RegisterExecutor 注册执行器，暂停注册时只记录注册信息，恢复注册时再注册
 func (s *XxlAdminServer) RegisterExecutor() {
 	if s.executor.AppName == "" {
 		panic("appName is executor name, it can't be null")
 	}
 
-	param := &transport.RegistryParam{
+	s.registerLock.Lock()
+	defer s.registerLock.Unlock()
+	s.Registry = &transport.RegistryParam{
 		RegistryGroup: "EXECUTOR",
 		RegistryKey:   s.executor.AppName,
 		RegistryValue: s.executor.GetRegisterAddr(),
 	}
-	s.Registry = param
+	if s.paused {
+		log.Print("job executor register paused, skip register")
+		return
+	}
 
 	hasValid := s.requestAdminApi(s.registerExe, s.Registry)
 	if !hasValid {
@@ -74,24 +83,72 @@ func (s *XxlAdminServer) RegisterExecutor() {
 }
 
 func (s *XxlAdminServer) AutoRegisterJobGroup() {
-	s.Registry.RegistryValue = s.executor.GetRegisterAddr()
 	t := time.NewTicker(s.BeatTime)
 	for {
 		select {
 		case <-t.C:
-			res := s.requestAdminApi(s.registerExe, s.Registry)
-			if !res {
-				log.Print("register job executor failed")
-			}
+			s.renewRegister()
 		}
 	}
 }
 
+// renewRegister 定时续期注册，与暂停注册互斥，暂停后不再注册
+func (s *XxlAdminServer) renewRegister() {
+	s.registerLock.Lock()
+	defer s.registerLock.Unlock()
+	if s.paused || s.Registry == nil {
+		return
+	}
+	s.Registry.RegistryValue = s.executor.GetRegisterAddr()
+	if !s.requestAdminApi(s.registerExe, s.Registry) {
+		log.Print("register job executor failed")
+	}
+}
+
 func (s *XxlAdminServer) RemoveRegisterExecutor() {
+	s.registerLock.Lock()
+	defer s.registerLock.Unlock()
+	s.removeRegister()
+}
+
+// removeRegister 移除执行器注册，调用方需持有registerLock
+func (s *XxlAdminServer) removeRegister() {
+	if s.Registry == nil {
+		// 还没有注册
+		return
+	}
 	log.Print("remove job executor register")
 	s.requestAdminApi(s.removerRegister, s.Registry)
 }
 
+// PauseRegister 暂停自动注册并移除执行器注册，admin不再调度到该执行器
+func (s *XxlAdminServer) PauseRegister() {
+	s.registerLock.Lock()
+	defer s.registerLock.Unlock()
+	if !s.paused {
+		s.paused = true
+		s.removeRegister()
+	}
+}
+
+// ResumeRegister 重新注册执行器并恢复自动注册
+func (s *XxlAdminServer) ResumeRegister() {
+	s.registerLock.Lock()
+	defer s.registerLock.Unlock()
+	if !s.paused {
+		return
+	}
+	s.paused = false
+	if s.Registry == nil {
+		// 还没有注册，由RegisterExecutor注册
+		return
+	}
+	log.Print("resume job executor register")
+	if !s.requestAdminApi(s.registerExe, s.Registry) {
+		log.Print("register job executor failed")
+	}
+}
+
 func (s *XxlAdminServer) CallbackAdmin(callbackParam []*transport.HandleCallbackParam) {
 	res := s.requestAdminApi(s.apiCallback, callbackParam)
 	if !res {
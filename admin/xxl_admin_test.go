@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gongshen/xxl-job-client/executor"
+)
+
+// fakeAdmin 记录执行器请求admin的接口
+type fakeAdmin struct {
+	sync.Mutex
+	paths []string
+}
+
+func (f *fakeAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	f.paths = append(f.paths, r.URL.Path)
+	f.Unlock()
+	w.Write([]byte(`{"code":200}`))
+}
+
+func (f *fakeAdmin) take() []string {
+	f.Lock()
+	defer f.Unlock()
+	paths := f.paths
+	f.paths = nil
+	return paths
+}
+
+func newTestAdminServer(t *testing.T) (*XxlAdminServer, *fakeAdmin) {
+	admin := &fakeAdmin{}
+	srv := httptest.NewServer(admin)
+	t.Cleanup(srv.Close)
+	return NewAdminServer([]string{srv.URL}, time.Second, 10*time.Second, executor.NewExecutor("test", 9999)), admin
+}
+
+func TestPauseBeforeRegister(t *testing.T) {
+	s, admin := newTestAdminServer(t)
+
+	// 注册前暂停，不请求admin，注册时跳过
+	s.PauseRegister()
+	s.RegisterExecutor()
+	s.renewRegister()
+	if paths := admin.take(); len(paths) != 0 {
+		t.Fatalf("paused executor requested admin: %v", paths)
+	}
+
+	s.ResumeRegister()
+	if paths := admin.take(); len(paths) != 1 || paths[0] != "/api/registry" {
+		t.Fatalf("resume register requested %v, want [/api/registry]", paths)
+	}
+}
+
+func TestPauseStopsRenew(t *testing.T) {
+	s, admin := newTestAdminServer(t)
+	s.RegisterExecutor()
+	admin.take()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.renewRegister()
+		}()
+	}
+	s.PauseRegister()
+	wg.Wait()
+
+	// 暂停后的续期不再注册，移除注册是最后一次请求
+	paths := admin.take()
+	if len(paths) == 0 || paths[len(paths)-1] != "/api/registryRemove" {
+		t.Fatalf("requests %v, want /api/registryRemove last", paths)
+	}
+	s.renewRegister()
+	if paths = admin.take(); len(paths) != 0 {
+		t.Fatalf("paused executor renewed register: %v", paths)
+	}
+}
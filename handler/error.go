@@ -1,5 +1,138 @@
 package handler
 
+import (
+	"errors"
+	"net/http"
+)
+
+// 任务结果回调admin的handleCode，同Java XxlJobContext
+const (
+	HandleCodeSuccess = http.StatusOK
+	HandleCodeFail    = http.StatusInternalServerError
+	HandleCodeTimeout = http.StatusBadGateway
+)
+
+// ErrorKind 任务执行结果分类
+type ErrorKind int
+
+const (
+	KindFailed  ErrorKind = iota //执行失败，handleCode 500
+	KindTimeout                  //执行超时，handleCode 502
+	KindKilled                   //任务被终止，handleCode 500
+	KindSkipped                  //调度被丢弃或跳过，任务未执行，handleCode 200
+	KindWarning                  //执行成功但有警告，handleCode 200
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindFailed:
+		return "job failed"
+	case KindTimeout:
+		return "job timeout"
+	case KindKilled:
+		return "job killed"
+	case KindSkipped:
+		return "job skipped"
+	case KindWarning:
+		return "job success with warning"
+	}
+	return "unknown"
+}
+
+// HandleCode 分类对应的回调handleCode
+func (k ErrorKind) HandleCode() int32 {
+	switch k {
+	case KindTimeout:
+		return HandleCodeTimeout
+	case KindSkipped, KindWarning:
+		return HandleCodeSuccess
+	}
+	return HandleCodeFail
+}
+
+// JobError 带分类的任务错误，任务返回的错误可用Failed、Timeout等包装以回调对应的handleCode
+type JobError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *JobError) Error() string {
+	if e.Err == nil {
+		return e.Kind.String()
+	}
+	return e.Kind.String() + ": " + e.Err.Error()
+}
+
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+func newJobError(kind ErrorKind, err error) error {
+	return &JobError{Kind: kind, Err: err}
+}
+
+func Failed(err error) error {
+	return newJobError(KindFailed, err)
+}
+
+func Timeout(err error) error {
+	return newJobError(KindTimeout, err)
+}
+
+func Killed(err error) error {
+	return newJobError(KindKilled, err)
+}
+
+func Skipped(msg string) error {
+	return newJobError(KindSkipped, errors.New(msg))
+}
+
+// Warning 任务执行成功，但回调admin时带上警告信息
+func Warning(msg string) error {
+	return newJobError(KindWarning, errors.New(msg))
+}
+
+// KindOf 获取错误分类，没有分类的错误为KindFailed
+func KindOf(err error) ErrorKind {
+	var je *JobError
+	if errors.As(err, &je) {
+		return je.Kind
+	}
+	var be *ExecutorBlockStrategyErr
+	if errors.As(err, &be) {
+		return KindSkipped
+	}
+	return KindFailed
+}
+
+// isFailure 错误回调admin时是否为失败，跳过及警告不算失败
+func isFailure(err error) bool {
+	return err != nil && KindOf(err).HandleCode() != HandleCodeSuccess
+}
+
+func IsTimeout(err error) bool {
+	return err != nil && KindOf(err) == KindTimeout
+}
+
+func IsKilled(err error) bool {
+	return err != nil && KindOf(err) == KindKilled
+}
+
+func IsSkipped(err error) bool {
+	return err != nil && KindOf(err) == KindSkipped
+}
+
+// HandleResult 任务结果转换为回调admin的handleCode及信息
+func HandleResult(err error) (code int32, msg string) {
+	if err == nil {
+		return HandleCodeSuccess, "success"
+	}
+	return KindOf(err).HandleCode(), err.Error()
+}
+
+// ExecutorBlockStrategyErr 阻塞处理策略丢弃调度的错误，包装在KindSkipped的JobError中，可通过errors.As获取
+//
+// Deprecated: 使用IsSkipped判断调度是否被丢弃
 type ExecutorBlockStrategyErr struct {
 	msg string
 }
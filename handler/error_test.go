@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gongshen/xxl-job-client/constants"
+)
+
+func TestHandleResult(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int32
+	}{
+		{nil, HandleCodeSuccess},
+		{errors.New("boom"), HandleCodeFail},
+		{Timeout(errors.New("slow")), HandleCodeTimeout},
+		{Killed(errors.New("stop")), HandleCodeFail},
+		{Skipped("skip"), HandleCodeSuccess},
+		{Warning("warn"), HandleCodeSuccess},
+		{&ExecutorBlockStrategyErr{msg: "discard"}, HandleCodeSuccess},
+	}
+	for _, c := range cases {
+		if code, _ := HandleResult(c.err); code != c.code {
+			t.Errorf("HandleResult(%v) code %d, want %d", c.err, code, c.code)
+		}
+	}
+}
+
+func TestDiscardLaterKeepsBlockStrategyErr(t *testing.T) {
+	j, results := newTestJobHandler()
+	started := make(chan struct{}, 1)
+	j.RegisterJob("block", func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := j.PutJobToQueue(newTrigger(1, 1, "block")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	trigger := newTrigger(1, 2, "block")
+	trigger.ExecutorBlockStrategy = constants.DiscardLater
+	err := j.PutJobToQueue(trigger)
+	var be *ExecutorBlockStrategyErr
+	if !IsSkipped(err) || !errors.As(err, &be) {
+		t.Fatalf("want skipped ExecutorBlockStrategyErr, got %v", err)
+	}
+	j.cancelJob(1)
+	waitResult(t, results)
+	j.clearJob()
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
@@ -19,6 +20,9 @@ import (
 	"github.com/gongshen/xxl-job-client/transport"
 )
 
+// 脚本被取消或结束后等待输出复制完成的最长时间
+const scriptWaitDelay = time.Second
+
 var scriptMap = map[string]string{
 	"GLUE_SHELL":      ".sh",
 	"GLUE_PYTHON":     ".py",
@@ -37,11 +41,27 @@ var scriptCmd = map[string]string{
 
 type ExecuteHandler interface {
 	ParseJob(trigger *transport.TriggerParam) (runParam *JobRunParam, err error)
-	Execute(jobId int32, glueType string, runParam *JobRunParam) error
+	Execute(ctx context.Context, jobId int32, glueType string, runParam *JobRunParam) error
+}
+
+// jobLifecycle 任务线程启动、停止时的回调
+type jobLifecycle interface {
+	Init(ctx context.Context) error
+	Destroy(ctx context.Context) error
 }
 
 type ScriptHandler struct {
 	sync.RWMutex
+
+	//GLUE脚本存放目录，为空时使用constants.GlueSourcePath
+	GlueSourcePath string
+}
+
+func (s *ScriptHandler) glueSourcePath() string {
+	if s.GlueSourcePath == "" {
+		return constants.GlueSourcePath
+	}
+	return s.GlueSourcePath
 }
 
 // ParseJob 根据trigger参数获取JobRun参数
@@ -50,6 +70,7 @@ func (s *ScriptHandler) ParseJob(trigger *transport.TriggerParam) (jobParam *Job
 	if !ok {
 		logParam := make(map[string]interface{})
 		logParam["logId"] = trigger.LogId
+		logParam["logDateTime"] = trigger.LogDateTime
 		logParam["jobId"] = trigger.JobId
 
 		jobParamMap := make(map[string]map[string]interface{})
@@ -57,11 +78,11 @@ func (s *ScriptHandler) ParseJob(trigger *transport.TriggerParam) (jobParam *Job
 		ctx := context.WithValue(context.Background(), "jobParam", jobParamMap)
 
 		msg := "暂不支持" + strings.ToLower(trigger.GlueType[constants.GluePrefixLen:]) + "脚本"
-		logger.Info(ctx, "job parse error:", msg)
+		logger.Error(ctx, "job parse error:", msg)
 		return jobParam, errors.New(msg)
 	}
 
-	path := fmt.Sprintf("%s%d_%d%s", constants.GlueSourcePath, trigger.JobId, trigger.GlueUpdatetime, suffix)
+	path := filepath.Join(s.glueSourcePath(), fmt.Sprintf("%d_%d%s", trigger.JobId, trigger.GlueUpdatetime, suffix))
 	_, err = os.Stat(path)
 	if err != nil && os.IsNotExist(err) {
 		log.Printf("script file not exist,need create. jobId:%d,content:%s\n", trigger.JobId, trigger.GlueSource)
@@ -69,7 +90,7 @@ func (s *ScriptHandler) ParseJob(trigger *transport.TriggerParam) (jobParam *Job
 		defer s.Unlock()
 		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0750)
 		if err != nil && os.IsNotExist(err) {
-			err = os.MkdirAll(constants.GlueSourcePath, os.ModePerm)
+			err = os.MkdirAll(s.glueSourcePath(), os.ModePerm)
 			if err == nil {
 				file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0750)
 				if err != nil {
@@ -102,6 +123,7 @@ func (s *ScriptHandler) ParseJob(trigger *transport.TriggerParam) (jobParam *Job
 		JobTag:                path,
 		InputParam:            inputParam,
 		ExecutorBlockStrategy: trigger.ExecutorBlockStrategy,
+		ExecutorTimeout:       trigger.ExecutorTimeout,
 	}
 	if trigger.BroadcastTotal > 0 {
 		jobParam.ShardIdx = trigger.BroadcastIndex
@@ -110,9 +132,10 @@ func (s *ScriptHandler) ParseJob(trigger *transport.TriggerParam) (jobParam *Job
 	return jobParam, nil
 }
 
-func (s *ScriptHandler) Execute(jobId int32, glueType string, runParam *JobRunParam) error {
+func (s *ScriptHandler) Execute(runCtx context.Context, jobId int32, glueType string, runParam *JobRunParam) error {
 	logParam := make(map[string]interface{})
 	logParam["logId"] = runParam.LogId
+	logParam["logDateTime"] = runParam.LogDateTime
 	logParam["jobId"] = jobId
 	logParam["jobName"] = runParam.JobName
 	logParam["jobFunc"] = runParam.JobTag
@@ -127,13 +150,7 @@ func (s *ScriptHandler) Execute(jobId int32, glueType string, runParam *JobRunPa
 	jobParam["sharding"] = shardParam
 	ctx := context.WithValue(context.Background(), "jobParam", jobParam)
 
-	basePath := logger.GetLogPath(time.Now())
-	if _, err := os.Stat(basePath); os.IsNotExist(err) {
-		s.Lock()
-		os.MkdirAll(basePath, os.ModePerm)
-		s.Unlock()
-	}
-	logPath := basePath + fmt.Sprintf("/%d", runParam.LogId) + ".log"
+	logPath := logger.GetLogFile(runParam.LogDateTime, runParam.LogId)
 	args := make([]string, 0)
 	//args = append(args, "-c")
 	// 放入脚本位置参数
@@ -150,30 +167,49 @@ func (s *ScriptHandler) Execute(jobId int32, glueType string, runParam *JobRunPa
 	args = append(args, strconv.Itoa(int(runParam.ShardIdx)))
 	args = append(args, strconv.Itoa(int(runParam.ShardTotal)))
 
-	cancelCtx, canFun := context.WithCancel(context.Background())
-	defer canFun()
-
-	runParam.CurrentCancelFunc = canFun
-	cmd := exec.CommandContext(cancelCtx, scriptCmd[glueType], args...)
+	cmd := exec.CommandContext(runCtx, scriptCmd[glueType], args...)
+	// 输出写入RunLog时通过管道复制，脚本启动的子进程持有管道会导致Wait阻塞，
+	// 取消时结束整个进程组，并限制结束后等待输出的时间
+	setProcessGroup(cmd)
+	cmd.WaitDelay = scriptWaitDelay
 	logger.Info(ctx, fmt.Sprintf("Script Execute. jobId:%d,logPath:%s,cmd:%s", jobId, logPath, strings.Join(args, " ")))
-	// 文件需要append属性
-	f, _ := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_SYNC|os.O_APPEND, 0755)
-	defer f.Close()
-	cmd.Stdout = f
-	cmd.Stderr = f
+	// 脚本输出与任务日志写入同一个RunLog，保持输出顺序
+	runLog := logger.CurrentRun(runParam.LogId)
+	if runLog == nil {
+		runLog = logger.OpenRun(runParam.LogDateTime, runParam.LogId, jobId, glueType)
+		defer runLog.Close()
+	}
+	if runLog != nil {
+		cmd.Stdout = runLog
+		cmd.Stderr = runLog
+	}
 	if err := cmd.Run(); err != nil {
-		logger.Info(ctx, "run script job err:", err)
+		logger.Error(ctx, "run script job err:", err)
 		return err
 	}
 	return nil
 }
 
 type BeanHandler struct {
-	RunFunc JobHandlerFunc
+	Job LifecycleJobHandler
+
+	//任务拦截器，第一个拦截器在最外层
+	Middlewares []JobMiddleware
+
+	//执行成功但有ERROR级别日志时，回调信息带上ERROR日志数量
+	CountErrors bool
+}
+
+func (b *BeanHandler) Init(ctx context.Context) error {
+	return b.Job.Init(ctx)
+}
+
+func (b *BeanHandler) Destroy(ctx context.Context) error {
+	return b.Job.Destroy(ctx)
 }
 
 func (b *BeanHandler) ParseJob(trigger *transport.TriggerParam) (jobParam *JobRunParam, err error) {
-	if b.RunFunc == nil {
+	if b.Job == nil {
 		return jobParam, errors.New("job run function not found")
 	}
 
@@ -192,7 +228,7 @@ func (b *BeanHandler) ParseJob(trigger *transport.TriggerParam) (jobParam *JobRu
 		}
 	}
 
-	funName := getFunctionName(b.RunFunc)
+	funName := getFunctionName(b.Job)
 	jobParam = &JobRunParam{
 		LogId:                 trigger.LogId,
 		LogDateTime:           trigger.LogDateTime,
@@ -200,13 +236,19 @@ func (b *BeanHandler) ParseJob(trigger *transport.TriggerParam) (jobParam *JobRu
 		JobTag:                funName,
 		InputParam:            inputParam,
 		ExecutorBlockStrategy: trigger.ExecutorBlockStrategy,
+		ExecutorTimeout:       trigger.ExecutorTimeout,
+	}
+	if trigger.BroadcastTotal > 0 {
+		jobParam.ShardIdx = trigger.BroadcastIndex
+		jobParam.ShardTotal = trigger.BroadcastTotal
 	}
 	return jobParam, err
 }
 
-func (b *BeanHandler) Execute(jobId int32, _ string, runParam *JobRunParam) (err error) {
+func (b *BeanHandler) Execute(runCtx context.Context, jobId int32, _ string, runParam *JobRunParam) (err error) {
 	logParam := make(map[string]interface{})
 	logParam["logId"] = runParam.LogId
+	logParam["logDateTime"] = runParam.LogDateTime
 	logParam["jobId"] = jobId
 	logParam["jobName"] = runParam.JobName
 	logParam["jobFunc"] = runParam.JobTag
@@ -220,29 +262,31 @@ func (b *BeanHandler) Execute(jobId int32, _ string, runParam *JobRunParam) (err
 	jobParam["inputParam"] = runParam.InputParam
 	jobParam["sharding"] = shardParam
 
-	valueCtx, canFun := context.WithCancel(context.Background())
-	defer canFun()
-
-	runParam.CurrentCancelFunc = canFun
-	ctx := context.WithValue(valueCtx, "jobParam", jobParam)
-	// add recover
-	defer func() {
-		r := recover()
-		if r != nil {
-			err = errors.New(fmt.Sprintf("panic:%v", r))
-			logger.Info(ctx, "job run failed! msg:", err.Error())
-		}
-	}()
-
-	err = b.RunFunc(ctx)
-	if err != nil {
-		logger.Info(ctx, "job run failed! msg:", err.Error())
-		return
+	ctx := context.WithValue(runCtx, "jobParam", jobParam)
+	ctx = withJobInfo(ctx, &JobInfo{
+		JobId:       jobId,
+		LogId:       runParam.LogId,
+		LogDateTime: runParam.LogDateTime,
+		JobName:     runParam.JobName,
+		JobTag:      runParam.JobTag,
+		InputParam:  runParam.InputParam,
+		ShardIdx:    runParam.ShardIdx,
+		ShardTotal:  runParam.ShardTotal,
+	})
+	if !b.CountErrors {
+		return chainMiddleware(b.Job.Execute, b.Middlewares...)(ctx)
 	}
-
-	return
+	ctx = logger.WithRunStats(ctx)
+	err = chainMiddleware(b.Job.Execute, b.Middlewares...)(ctx)
+	if n := logger.ErrorCount(ctx); n > 0 && err == nil {
+		return Warning(fmt.Sprintf("completed with %d errors", n))
+	}
+	return err
 }
 
 func getFunctionName(i interface{}) string {
+	if reflect.TypeOf(i).Kind() != reflect.Func {
+		return reflect.TypeOf(i).String()
+	}
 	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
 }
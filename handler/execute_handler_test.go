@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestScriptKilledWithChildProcess(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+	j, results := newTestJobHandler()
+	j.GlueSourcePath = t.TempDir()
+	trigger := newTrigger(1, 4601, "")
+	trigger.GlueType = "GLUE_SHELL"
+	trigger.GlueUpdatetime = 1
+	// sleep作为子进程执行，持有输出管道
+	trigger.GlueSource = "echo start\nsleep 5\necho done\n"
+
+	if err := j.PutJobToQueue(trigger); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool { return j.HasRunning(1) })
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	j.cancelJob(1)
+	r := waitResult(t, results)
+	if !IsKilled(r.err) {
+		t.Fatalf("script want killed, got %v", r.err)
+	}
+	if cost := time.Since(start); cost > 2*time.Second {
+		t.Fatalf("script stopped after %s, child process blocked the job thread", cost)
+	}
+	j.clearJob()
+}
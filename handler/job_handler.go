@@ -3,10 +3,12 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/gongshen/xxl-job-client/constants"
 	"log"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gongshen/xxl-job-client/logger"
 	"github.com/gongshen/xxl-job-client/queue"
@@ -15,14 +17,54 @@ import (
 
 type JobHandlerFunc func(ctx context.Context) error
 
+func (f JobHandlerFunc) Init(context.Context) error { return nil }
+
+func (f JobHandlerFunc) Execute(ctx context.Context) error { return f(ctx) }
+
+func (f JobHandlerFunc) Destroy(context.Context) error { return nil }
+
+// LifecycleJobHandler 带生命周期的任务，同Java IJobHandler的init、destroy。
+// Init在jobId的任务线程创建后首次执行前调用，失败时下次执行前重试；
+// Destroy在任务线程被回收、重建或执行器退出时，正在执行的任务结束后调用
+type LifecycleJobHandler interface {
+	Init(ctx context.Context) error
+	Execute(ctx context.Context) error
+	Destroy(ctx context.Context) error
+}
+
+// toJobHandler 将注册的任务转换为LifecycleJobHandler，支持函数及LifecycleJobHandler
+func toJobHandler(job interface{}) (LifecycleJobHandler, error) {
+	switch h := job.(type) {
+	case LifecycleJobHandler:
+		return h, nil
+	case func(ctx context.Context) error:
+		return JobHandlerFunc(h), nil
+	}
+	return nil, fmt.Errorf("unsupported job type %T, must be JobHandlerFunc or LifecycleJobHandler", job)
+}
+
 type JobQueue struct {
-	JobId    int32
-	GlueType string
+	sync.Mutex
+	JobId           int32
+	GlueType        string
+	GlueUpdatetime  int64
+	ExecutorHandler string
 	ExecuteHandler
 	CurrentJob *JobRunParam
-	Run        int32 //0 stop, 1 run
-	Queue      *queue.Queue
+	Run        int32 //0 idle, 1 waiting for concurrency slot, 2 running
+	Queue      *queue.Queue[*JobRunParam]
 	Callback   func(int64, int64, error)
+
+	//执行前需要获取的并发名额，按获取顺序排列
+	Limits []*Semaphore
+
+	define        *jobDefine               //创建任务线程时注册的bean任务，任务被替换或注销后重建任务线程
+	prev          atomic.Pointer[JobQueue] //被替换的任务线程，结束后本任务线程才开始执行
+	done          chan struct{}
+	stop          context.CancelFunc
+	currentCancel context.CancelFunc
+	killed        bool  //正在执行的任务被取消
+	lastActive    int64 //最后一次调度或执行结束的时间，unix纳秒
 }
 
 type JobRunParam struct {
@@ -33,175 +75,737 @@ type JobRunParam struct {
 	InputParam            map[string]interface{}
 	ShardIdx              int32
 	ShardTotal            int32
-	CurrentCancelFunc     context.CancelFunc
 	ExecutorBlockStrategy string
+	ExecutorTimeout       int32 //任务超时时间，单位秒，大于零时生效
 }
 
+// StartJob 启动任务线程，任务线程阻塞等待队列中的调度直到StopJob
 func (jq *JobQueue) StartJob() {
-	if atomic.CompareAndSwapInt32(&jq.Run, 0, 1) {
-		jq.asyRunJob()
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	jq.stop = cancel
+	jq.done = make(chan struct{})
+	go jq.runJob(ctx)
 }
 
-func (jq *JobQueue) StopJob() bool {
-	return atomic.CompareAndSwapInt32(&jq.Run, 1, 0)
+// retire 停止任务线程但不取消正在执行的任务，返回队列中未执行的调度
+func (jq *JobQueue) retire() []*JobRunParam {
+	jq.stop()
+	return jq.Queue.Drain()
 }
 
-func (jq *JobQueue) asyRunJob() {
-	go func() {
-		for {
-			has, node := jq.Queue.Poll()
-			if has {
-				jq.CurrentJob = node.(*JobRunParam)
-				jq.Callback(jq.CurrentJob.LogId, jq.CurrentJob.LogDateTime, jq.Execute(jq.JobId, jq.GlueType, jq.CurrentJob))
+// StopJob 停止任务线程，取消正在执行的任务，队列中未执行的调度回调admin
+func (jq *JobQueue) StopJob(reason string) {
+	jq.stop()
+	if prev := jq.prev.Load(); prev != nil {
+		prev.StopJob(reason)
+	}
+	current := jq.cancelCurrent()
+	if current != nil {
+		go logger.Warn(newLogContext(jq.JobId, current), reason)
+	}
+	for _, runParam := range jq.Queue.Drain() {
+		go jq.Callback(runParam.LogId, runParam.LogDateTime, Killed(errors.New(reason+" [job not executed, in the job queue, killed.]")))
+	}
+}
+
+func (jq *JobQueue) runJob(ctx context.Context) {
+	defer close(jq.done)
+	if prev := jq.prev.Load(); prev != nil {
+		select {
+		case <-prev.done:
+			jq.prev.Store(nil)
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	lifecycle, hasLifecycle := jq.ExecuteHandler.(jobLifecycle)
+	inited := !hasLifecycle
+	defer func() {
+		if inited && hasLifecycle {
+			if err := callLifecycle(lifecycle.Destroy, context.Background()); err != nil {
+				log.Printf("job destroy failed. jobId:%d,err:%v\n", jq.JobId, err)
+				if pe, ok := err.(*PanicError); ok {
+					log.Printf("%s", pe.Stack)
+				}
+			}
+		}
+	}()
+
+	for {
+		runParam, err := jq.Queue.Take(ctx)
+		if err != nil {
+			return
+		}
+		atomic.StoreInt32(&jq.Run, 1)
+		handlerName := runParam.JobName
+		if handlerName == "" {
+			// GLUE任务没有JobHandler，使用GLUE类型
+			handlerName = jq.GlueType
+		}
+		runLog := logger.OpenRun(runParam.LogDateTime, runParam.LogId, jq.JobId, handlerName)
+		// 任务线程停止时不影响正在执行的任务，取消任务通过cancelCurrent
+		runCtx, cancel := newRunContext(runParam)
+		jq.setCurrent(runParam, cancel)
+		if !inited {
+			if err = callLifecycle(lifecycle.Init, runCtx); err != nil {
+				if pe, ok := err.(*PanicError); ok {
+					logger.Error(newLogContext(jq.JobId, runParam), "job init panic: ", fmt.Sprintf("%v", pe.Value), "\r\n", pe.Stack)
+				}
+				err = fmt.Errorf("job init failed: %w", err)
+				logger.Error(newLogContext(jq.JobId, runParam), err.Error())
 			} else {
-				jq.StopJob()
-				break
+				inited = true
 			}
 		}
+		if err == nil {
+			err = jq.acquire(runCtx, runParam)
+		}
+		if err == nil {
+			atomic.StoreInt32(&jq.Run, 2)
+			err = jq.execute(runCtx, runParam)
+			jq.release()
+		}
+		err = jq.runResult(runCtx, runParam, err)
+		if dropped := runLog.Dropped(); dropped > 0 {
+			log.Printf("job log truncated. jobId:%d,logId:%d,dropped:%d\n", jq.JobId, runParam.LogId, dropped)
+			err = truncatedResult(err, dropped)
+		}
+		if cerr := runLog.Close(); cerr != nil {
+			log.Printf("job log close failed. jobId:%d,logId:%d,err:%v\n", jq.JobId, runParam.LogId, cerr)
+		}
+		cancel()
+		jq.setCurrent(nil, nil)
+		atomic.StoreInt32(&jq.Run, 0)
+		jq.touch()
+		jq.Callback(runParam.LogId, runParam.LogDateTime, err)
+	}
+}
+
+// callLifecycle 调用任务的Init或Destroy，panic时转换为PanicError，避免任务线程崩溃导致执行器退出
+func callLifecycle(fn func(ctx context.Context) error, ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r, 0)
+		}
 	}()
+	return fn(ctx)
 }
 
+// execute 执行任务，panic时转换为PanicError。默认拦截器会先捕获panic，
+// 不使用默认拦截器时避免任务panic导致执行器退出
+func (jq *JobQueue) execute(ctx context.Context, runParam *JobRunParam) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pe := newPanicError(r, 0)
+			logger.Error(newLogContext(jq.JobId, runParam), "job panic: ", fmt.Sprintf("%v", r), "\r\n", pe.Stack)
+			err = pe
+		}
+	}()
+	return jq.Execute(ctx, jq.JobId, jq.GlueType, runParam)
+}
+
+// truncatedResult 任务日志超过限制被截断时，回调信息带上丢弃的字节数
+func truncatedResult(err error, dropped int64) error {
+	msg := fmt.Sprintf("job log truncated, %d bytes dropped", dropped)
+	if err == nil {
+		return Warning(msg)
+	}
+	return fmt.Errorf("%w (%s)", err, msg)
+}
+
+// newRunContext 任务执行的context，设置了超时时间时超时后取消
+func newRunContext(runParam *JobRunParam) (context.Context, context.CancelFunc) {
+	if runParam.ExecutorTimeout > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(runParam.ExecutorTimeout)*time.Second)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// runResult 根据任务是否被取消或超时对错误分类，任务返回的已分类错误保持不变
+func (jq *JobQueue) runResult(ctx context.Context, runParam *JobRunParam, err error) error {
+	var je *JobError
+	if err == nil || errors.As(err, &je) {
+		return err
+	}
+	jq.Lock()
+	killed := jq.killed
+	jq.Unlock()
+	if killed {
+		return Killed(err)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Error(newLogContext(jq.JobId, runParam), fmt.Sprintf("job execute timeout, timeout:%ds", runParam.ExecutorTimeout))
+		return Timeout(err)
+	}
+	return err
+}
+
+// acquire 依次获取执行所需的并发名额，获取失败时释放已获取的名额
+func (jq *JobQueue) acquire(ctx context.Context, runParam *JobRunParam) error {
+	for i, sem := range jq.Limits {
+		if sem.TryAcquire() {
+			continue
+		}
+		logger.Info(newLogContext(jq.JobId, runParam), "job queued, waiting for concurrency slot: ", sem.String())
+		if err := sem.Acquire(ctx); err != nil {
+			for _, acquired := range jq.Limits[:i] {
+				acquired.Release()
+			}
+			return fmt.Errorf("job canceled while waiting for concurrency slot %s: %w", sem.Name, err)
+		}
+	}
+	return nil
+}
+
+func (jq *JobQueue) release() {
+	for i := len(jq.Limits) - 1; i >= 0; i-- {
+		jq.Limits[i].Release()
+	}
+}
+
+// push 调度放入任务队列，队列已满时按溢出策略处理
+func (jq *JobQueue) push(runParam *JobRunParam) error {
+	dropped, hasDropped, err := jq.Queue.Put(runParam)
+	if err == queue.ErrItemDropped {
+		return Skipped(fmt.Sprintf("job queue is full, discard this trigger. capacity:%d", jq.Queue.Capacity()))
+	}
+	if err != nil {
+		return fmt.Errorf("job queue is full, reject this trigger. capacity:%d", jq.Queue.Capacity())
+	}
+	if hasDropped {
+		go func() {
+			msg := fmt.Sprintf("job queue is full, discard the oldest trigger. capacity:%d", jq.Queue.Capacity())
+			logger.Warn(newLogContext(jq.JobId, dropped), msg)
+			jq.Callback(dropped.LogId, dropped.LogDateTime, Skipped(msg))
+		}()
+	}
+	return nil
+}
+
+func (jq *JobQueue) setCurrent(runParam *JobRunParam, cancel context.CancelFunc) {
+	jq.Lock()
+	defer jq.Unlock()
+	jq.CurrentJob = runParam
+	jq.currentCancel = cancel
+	jq.killed = false
+}
+
+// cancelCurrent 取消正在执行的任务，返回被取消的任务
+func (jq *JobQueue) cancelCurrent() *JobRunParam {
+	jq.Lock()
+	defer jq.Unlock()
+	if jq.CurrentJob == nil || jq.currentCancel == nil {
+		return nil
+	}
+	jq.currentCancel()
+	jq.killed = true
+	return jq.CurrentJob
+}
+
+func (jq *JobQueue) touch() {
+	atomic.StoreInt64(&jq.lastActive, time.Now().UnixNano())
+}
+
+func (jq *JobQueue) isRunning() bool {
+	if prev := jq.prev.Load(); prev != nil && prev.isRunning() {
+		return true
+	}
+	return atomic.LoadInt32(&jq.Run) > 0 || jq.Queue.Len() > 0
+}
+
+// activeLogIds 正在执行及队列中等待执行的调度LogId
+func (jq *JobQueue) activeLogIds(logIds map[int64]struct{}) {
+	if prev := jq.prev.Load(); prev != nil {
+		prev.activeLogIds(logIds)
+	}
+	jq.Lock()
+	if jq.CurrentJob != nil {
+		logIds[jq.CurrentJob.LogId] = struct{}{}
+	}
+	jq.Unlock()
+	for _, runParam := range jq.Queue.Snapshot() {
+		logIds[runParam.LogId] = struct{}{}
+	}
+}
+
+// isIdle 任务线程没有运行且空闲超过idleTimeout
+func (jq *JobQueue) isIdle(idleTimeout time.Duration) bool {
+	if jq.isRunning() {
+		return false
+	}
+	return time.Since(time.Unix(0, atomic.LoadInt64(&jq.lastActive))) > idleTimeout
+}
+
+const defaultExitTimeout = 10 * time.Second
+
 type JobHandler struct {
 	sync.RWMutex
 
-	jobMap map[string]JobHandlerFunc
+	jobMap map[string]*jobDefine
 
-	QueueMap map[int32]*JobQueue
+	queueLock sync.RWMutex
+	queueMap  map[int32]*JobQueue
 
 	CallbackFunc func(int64, int64, error)
+
+	// 任务线程空闲超过该时间后回收，小于等于0时不回收
+	IdleTimeout time.Duration
+
+	// 任务队列默认容量及溢出策略，注册任务时可单独指定
+	QueueCapacity  int
+	OverflowPolicy queue.OverflowPolicy
+
+	// 执行器全局并发限制，为空时不限制
+	WorkerPool *Semaphore
+
+	// 不使用默认拦截器DefaultMiddlewares
+	DisableDefaultMiddleware bool
+
+	// 默认拦截器捕获panic的配置
+	Recover RecoverConfig
+
+	// GLUE脚本存放目录，为空时使用constants.GlueSourcePath
+	GlueSourcePath string
+
+	// 统计任务执行中ERROR级别日志数量
+	CountErrorLogs bool
+
+	// 执行器退出时等待任务线程结束的最长时间，小于等于0时使用defaultExitTimeout
+	ExitTimeout time.Duration
+
+	resources   map[string]*Semaphore
+	middlewares []JobMiddleware
+
+	exitOnce  sync.Once
+	closeOnce sync.Once
+	exit      chan struct{} //执行器退出时关闭，停止后台协程
 }
 
 func (j *JobHandler) BeanJobLength() int {
+	j.RLock()
+	defer j.RUnlock()
 	if j.jobMap == nil {
 		return 0
 	}
 	return len(j.jobMap)
 }
 
-func (j *JobHandler) RegisterJob(jobName string, function JobHandlerFunc) {
+// RegisterJob 注册bean任务，job为JobHandlerFunc或LifecycleJobHandler
+func (j *JobHandler) RegisterJob(jobName string, job interface{}, opts ...JobOption) {
+	jobHandler, err := toJobHandler(job)
+	if err != nil {
+		panic(err.Error() + ", job name:" + jobName)
+	}
+	define, err := newJobDefine(jobName, jobHandler, opts...)
+	if err != nil {
+		panic(err.Error() + ", job name:" + jobName)
+	}
 	j.Lock()
 	defer j.Unlock()
+	if j.hasJob(jobName) {
+		panic("the job had already register, job name can't be repeated:" + jobName)
+	}
+	j.addJob(jobName, define)
+}
+
+// hasJob 任务是否已注册，调用方需持有锁
+func (j *JobHandler) hasJob(jobName string) bool {
+	_, ok := j.jobMap[jobName]
+	return ok
+}
+
+// newJobDefine 根据任务配置创建注册的任务，配置无效时返回错误
+func newJobDefine(jobName string, jobHandler LifecycleJobHandler, opts ...JobOption) (*jobDefine, error) {
+	options, err := newJobOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	define := &jobDefine{
+		Handler: jobHandler,
+		Options: options,
+	}
+	if define.Options.MaxConcurrency > 0 {
+		define.limit = NewSemaphore("job:"+jobName, define.Options.MaxConcurrency)
+	}
+	return define, nil
+}
+
+// addJob 添加任务，调用方需持有锁
+func (j *JobHandler) addJob(jobName string, define *jobDefine) {
 	if j.jobMap == nil {
-		j.jobMap = make(map[string]JobHandlerFunc)
-	} else {
-		_, ok := j.jobMap[jobName]
-		if ok {
-			panic("the job had already register, job name can't be repeated:" + jobName)
-		}
+		j.jobMap = make(map[string]*jobDefine)
 	}
-	j.jobMap[jobName] = function
+	j.jobMap[jobName] = define
+}
+
+// Use 添加全局任务拦截器，对之后创建的任务线程生效
+func (j *JobHandler) Use(middlewares ...JobMiddleware) {
+	j.Lock()
+	defer j.Unlock()
+	j.middlewares = append(j.middlewares, middlewares...)
+}
+
+// DefaultMiddlewares 默认拦截器，记录失败日志及按Recover配置捕获panic。
+// 设置了DisableDefaultMiddleware时可通过Use重新添加
+func (j *JobHandler) DefaultMiddlewares() []JobMiddleware {
+	return []JobMiddleware{LoggingMiddleware, NewRecoverMiddleware(j.Recover)}
+}
+
+// jobMiddlewares 默认拦截器、全局拦截器、任务拦截器及重试依次组装，调用方需持有锁
+func (j *JobHandler) jobMiddlewares(define *jobDefine) []JobMiddleware {
+	var middlewares []JobMiddleware
+	if !j.DisableDefaultMiddleware {
+		middlewares = append(middlewares, j.DefaultMiddlewares()...)
+	}
+	middlewares = append(middlewares, j.middlewares...)
+	middlewares = append(middlewares, define.Options.Middlewares...)
+	if define.Options.Retry != nil && define.Options.Retry.MaxAttempts > 1 {
+		// 每次执行的panic在重试内捕获为PanicError，由Retryable判断是否重试
+		middlewares = append(middlewares, define.Options.Retry.middleware, NewRecoverMiddleware(j.Recover))
+	}
+	return middlewares
+}
+
+// UnregisterJob 注销bean任务，正在执行的任务继续执行，该任务的jobId下次调度时停止旧的任务线程
+func (j *JobHandler) UnregisterJob(jobName string) error {
+	j.Lock()
+	defer j.Unlock()
+	if !j.hasJob(jobName) {
+		return errors.New("the job not register, job name:" + jobName)
+	}
+	delete(j.jobMap, jobName)
+	return nil
+}
+
+// ReplaceJob 替换已注册的bean任务，正在执行的任务继续使用旧的任务执行，该任务的jobId下次调度时重建任务线程
+func (j *JobHandler) ReplaceJob(jobName string, job interface{}, opts ...JobOption) error {
+	jobHandler, err := toJobHandler(job)
+	if err != nil {
+		return err
+	}
+	define, err := newJobDefine(jobName, jobHandler, opts...)
+	if err != nil {
+		return err
+	}
+	j.Lock()
+	defer j.Unlock()
+	if !j.hasJob(jobName) {
+		return errors.New("the job not register, job name:" + jobName)
+	}
+	j.addJob(jobName, define)
+	return nil
+}
+
+// replacedReason 任务线程绑定的bean任务被替换或注销时返回原因
+func (j *JobHandler) replacedReason(qu *JobQueue) string {
+	if qu.ExecutorHandler == "" {
+		return ""
+	}
+	j.RLock()
+	defer j.RUnlock()
+	define, ok := j.jobMap[qu.ExecutorHandler]
+	if !ok {
+		return "jobhandler unregistered, and stop the old job thread after the running job finished."
+	}
+	if define != qu.define {
+		return "jobhandler replaced, and stop the old job thread after the running job finished."
+	}
+	return ""
+}
+
+// RegisterResource 注册共享资源，多个任务通过WithResources共享该资源的并发限制，limit需大于0
+func (j *JobHandler) RegisterResource(name string, limit int) {
+	if limit <= 0 {
+		panic(fmt.Sprintf("resource limit must be positive, resource name:%s,limit:%d", name, limit))
+	}
+	j.Lock()
+	defer j.Unlock()
+	if j.resources == nil {
+		j.resources = make(map[string]*Semaphore)
+	} else if _, ok := j.resources[name]; ok {
+		panic("the resource had already register, resource name can't be repeated:" + name)
+	}
+	j.resources[name] = NewSemaphore("resource:"+name, limit)
+}
+
+// Saturated 执行器全局并发名额是否已用完
+func (j *JobHandler) Saturated() bool {
+	return j.WorkerPool != nil && j.WorkerPool.Saturated()
 }
 
 func (j *JobHandler) HasRunning(jobId int32) bool {
-	qu, has := j.QueueMap[jobId]
+	j.queueLock.RLock()
+	defer j.queueLock.RUnlock()
+	qu, has := j.queueMap[jobId]
 	if has {
-		if qu.Run > 0 || qu.Queue.HasNext() {
-			return true
-		}
+		return qu.isRunning()
 	}
 	return false
 }
 
+// changeReason 判断任务的handler、glue类型或glue脚本是否变更，变更时返回原因
+func (jq *JobQueue) changeReason(trigger *transport.TriggerParam) string {
+	if jq.ExecutorHandler != trigger.ExecutorHandler || jq.GlueType != trigger.GlueType {
+		return "change jobhandler or glue type, and terminate the old job thread."
+	}
+	if jq.GlueUpdatetime != trigger.GlueUpdatetime {
+		return "change job source or glue type, and terminate the old job thread."
+	}
+	return ""
+}
+
 func (j *JobHandler) PutJobToQueue(trigger *transport.TriggerParam) (err error) {
-	qu, has := j.QueueMap[trigger.JobId] //map value是地址，读不加锁
+	// 同一时间只允许一个调度修改任务队列，避免并发调度重复创建队列
+	j.queueLock.Lock()
+	defer j.queueLock.Unlock()
+
+	var removeReason string
+	var retired *JobQueue
+	var pending []*JobRunParam
+	qu, has := j.queueMap[trigger.JobId]
 	if has {
-		if trigger.ExecutorBlockStrategy == constants.DiscardLater {
-			if atomic.LoadInt32(&qu.Run) == 1 {
-				// 丢弃本次调度
-				return &ExecutorBlockStrategyErr{
-					msg: "job正在执行，丢弃本地调度",
-				}
+		qu.touch()
+		// 任务变更，停止旧的任务线程后重建
+		removeReason = qu.changeReason(trigger)
+		if removeReason == "" {
+			// 任务被替换或注销，旧的任务线程执行完当前任务后停止，未执行的调度转到新的任务线程。
+			// 旧的任务线程保留在任务map中直到被新的任务线程替换，创建失败时仍可被回收及退出时等待
+			if removeReason = j.replacedReason(qu); removeReason != "" {
+				pending = qu.retire()
+				retired, has = qu, false
 			}
-		} else if trigger.ExecutorBlockStrategy == constants.CoverEarly {
-			if atomic.LoadInt32(&qu.Run) == 1 {
+		}
+		if removeReason == "" && qu.isRunning() {
+			if trigger.ExecutorBlockStrategy == constants.DiscardLater {
+				// 丢弃本次调度
+				return newJobError(KindSkipped, &ExecutorBlockStrategyErr{msg: "job正在执行，丢弃本地调度"})
+			} else if trigger.ExecutorBlockStrategy == constants.CoverEarly {
 				// 杀掉队列中的任务
-				j.cancelJob(trigger.JobId)
-				goto initQueue
+				removeReason = "block strategy effect：Cover Early [job running, killed]"
 			}
 		}
-		runParam, err := qu.ParseJob(trigger)
+		if removeReason != "" && retired == nil {
+			j.removeQueue(qu, removeReason)
+			has = false
+		}
+	}
+
+	if !has {
+		qu, err = j.newJobQueue(trigger)
 		if err != nil {
+			for _, runParam := range pending {
+				go j.CallbackFunc(runParam.LogId, runParam.LogDateTime, err)
+			}
 			return err
 		}
-		err = qu.Queue.Put(runParam)
-		if err == nil {
-			qu.StartJob()
-			return err
+		if retired != nil {
+			qu.prev.Store(retired)
+		}
+		j.queueMap[trigger.JobId] = qu
+		qu.StartJob()
+		for _, runParam := range pending {
+			if err = qu.push(runParam); err != nil {
+				go qu.Callback(runParam.LogId, runParam.LogDateTime, err)
+			}
 		}
-		return err
 	}
 
-initQueue:
-	j.Lock() //任务map初始化锁
-	defer j.Unlock()
+	runParam, err := qu.ParseJob(trigger)
+	if err != nil {
+		return err
+	}
+	if removeReason != "" {
+		logger.Warn(newLogContext(trigger.JobId, runParam), removeReason)
+	}
+	return qu.push(runParam)
+}
 
+// newJobQueue 根据调度参数创建任务队列
+func (j *JobHandler) newJobQueue(trigger *transport.TriggerParam) (*JobQueue, error) {
 	jobQueue := &JobQueue{
-		GlueType: trigger.GlueType,
-		JobId:    trigger.JobId,
-		Callback: j.CallbackFunc,
+		GlueType:        trigger.GlueType,
+		GlueUpdatetime:  trigger.GlueUpdatetime,
+		ExecutorHandler: trigger.ExecutorHandler,
+		JobId:           trigger.JobId,
+		Callback:        j.CallbackFunc,
 	}
+	capacity, policy := j.QueueCapacity, j.OverflowPolicy
 	if trigger.ExecutorHandler != "" {
-		if j.jobMap == nil && len(j.jobMap) <= 0 {
-			return errors.New("bean job handler not found")
-		}
-		fun, ok := j.jobMap[trigger.ExecutorHandler]
+		j.RLock()
+		defer j.RUnlock()
+		job, ok := j.jobMap[trigger.ExecutorHandler]
 		if !ok {
-			return errors.New("bean job handler not found")
+			return nil, errors.New("bean job handler not found")
 		}
 
 		jobQueue.ExecuteHandler = &BeanHandler{
-			RunFunc: fun,
+			Job:         job.Handler,
+			Middlewares: j.jobMiddlewares(job),
+			CountErrors: j.CountErrorLogs,
+		}
+		jobQueue.define = job
+		if job.Options.QueueCapacity > 0 {
+			capacity, policy = job.Options.QueueCapacity, job.Options.OverflowPolicy
 		}
+		if job.limit != nil {
+			jobQueue.Limits = append(jobQueue.Limits, job.limit)
+		}
+		for _, name := range job.Options.Resources {
+			resource, ok := j.resources[name]
+			if !ok {
+				return nil, errors.New("job resource not found: " + name)
+			}
+			jobQueue.Limits = append(jobQueue.Limits, resource)
+		}
+		sortSemaphores(jobQueue.Limits)
 	} else {
-		jobQueue.ExecuteHandler = &ScriptHandler{}
-	}
-
-	runParam, err := jobQueue.ParseJob(trigger)
-	if err != nil {
-		return err
+		jobQueue.ExecuteHandler = &ScriptHandler{
+			GlueSourcePath: j.GlueSourcePath,
+		}
 	}
-	q := queue.NewQueue()
-	err = q.Put(runParam)
-	if err != nil {
-		return err
+	// 全局名额最后获取，避免等待任务名额时占用全局名额
+	if j.WorkerPool != nil {
+		jobQueue.Limits = append(jobQueue.Limits, j.WorkerPool)
 	}
-
-	jobQueue.Queue = q
-	j.QueueMap[trigger.JobId] = jobQueue
-	jobQueue.StartJob()
-	return err
+	jobQueue.Queue = queue.NewQueue[*JobRunParam](capacity, policy)
+	jobQueue.touch()
+	return jobQueue, nil
 }
 
 func (j *JobHandler) cancelJob(jobId int32) {
-	queue, has := j.QueueMap[jobId]
+	j.queueLock.Lock()
+	defer j.queueLock.Unlock()
+	queue, has := j.queueMap[jobId]
 	if has {
 		log.Print("job be canceled, id:", jobId)
-		res := queue.StopJob()
-		if res {
-			if queue.CurrentJob != nil && queue.CurrentJob.CurrentCancelFunc != nil {
-				queue.CurrentJob.CurrentCancelFunc()
-
-				go func() {
-					jobParam := make(map[string]map[string]interface{})
-					logParam := make(map[string]interface{})
-					logParam["logId"] = queue.CurrentJob.LogId
-					logParam["jobId"] = jobId
-					logParam["jobName"] = queue.CurrentJob.JobName
-					logParam["jobFunc"] = queue.CurrentJob.JobTag
-					jobParam["logParam"] = logParam
-					ctx := context.WithValue(context.Background(), "jobParam", jobParam)
-					logger.Info(ctx, "job canceled by admin!")
-				}()
-			}
-			if queue.Queue != nil {
-				queue.Queue.Clear()
+		j.removeQueue(queue, "job canceled by admin!")
+	}
+}
+
+// removeQueue 停止任务线程并从任务map中移除，调用方需持有queueLock
+func (j *JobHandler) removeQueue(queue *JobQueue, reason string) {
+	queue.StopJob(reason)
+	if j.queueMap[queue.JobId] == queue {
+		delete(j.queueMap, queue.JobId)
+	}
+}
+
+// ActiveLogIds 所有正在执行及等待执行的调度LogId
+func (j *JobHandler) ActiveLogIds() map[int64]struct{} {
+	j.queueLock.RLock()
+	defer j.queueLock.RUnlock()
+	logIds := make(map[int64]struct{})
+	for _, qu := range j.queueMap {
+		qu.activeLogIds(logIds)
+	}
+	return logIds
+}
+
+// IsLogActive 调度是否正在执行或等待执行
+func (j *JobHandler) IsLogActive(logId int64) bool {
+	_, ok := j.ActiveLogIds()[logId]
+	return ok
+}
+
+// ActiveGlueSources 任务线程正在使用的GLUE脚本文件名前缀 {jobId}_{glueUpdatetime}
+func (j *JobHandler) ActiveGlueSources() map[string]struct{} {
+	j.queueLock.RLock()
+	defer j.queueLock.RUnlock()
+	sources := make(map[string]struct{})
+	for _, qu := range j.queueMap {
+		for q := qu; q != nil; q = q.prev.Load() {
+			if q.ExecutorHandler == "" {
+				sources[fmt.Sprintf("%d_%d", q.JobId, q.GlueUpdatetime)] = struct{}{}
 			}
 		}
 	}
+	return sources
+}
+
+// AutoEvictIdleQueue 定时回收空闲的任务线程
+func (j *JobHandler) AutoEvictIdleQueue() {
+	if j.IdleTimeout <= 0 {
+		return
+	}
+	t := time.NewTicker(j.IdleTimeout / 2)
+	defer t.Stop()
+	exit := j.exitChan()
+	for {
+		select {
+		case <-t.C:
+			j.evictIdleQueue()
+		case <-exit:
+			return
+		}
+	}
+}
+
+func (j *JobHandler) exitChan() chan struct{} {
+	j.exitOnce.Do(func() {
+		j.exit = make(chan struct{})
+	})
+	return j.exit
+}
+
+func (j *JobHandler) evictIdleQueue() {
+	j.queueLock.Lock()
+	defer j.queueLock.Unlock()
+	for jobId, qu := range j.queueMap {
+		if qu.isIdle(j.IdleTimeout) {
+			log.Printf("job thread idle timeout, remove it. jobId:%d\n", jobId)
+			j.removeQueue(qu, "job thread idle timeout, removed.")
+		}
+	}
 }
 
 func (j *JobHandler) clearJob() {
-	j.jobMap = map[string]JobHandlerFunc{}
-	j.QueueMap = make(map[int32]*JobQueue)
+	j.closeOnce.Do(func() {
+		close(j.exitChan())
+	})
+
+	j.Lock()
+	j.jobMap = map[string]*jobDefine{}
+	j.Unlock()
+
+	j.queueLock.Lock()
+	queues := make([]*JobQueue, 0, len(j.queueMap))
+	for _, qu := range j.queueMap {
+		for q := qu; q != nil; q = q.prev.Load() {
+			queues = append(queues, q)
+		}
+		qu.StopJob("executor exit, job thread stopped.")
+	}
+	j.queueMap = make(map[int32]*JobQueue)
+	j.queueLock.Unlock()
+
+	// 等待任务线程退出，任务的Destroy在任务线程中执行
+	timeout := j.ExitTimeout
+	if timeout <= 0 {
+		timeout = defaultExitTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for _, q := range queues {
+		select {
+		case <-q.done:
+		case <-deadline.C:
+			log.Printf("wait job thread exit timeout, timeout:%s\n", timeout)
+			return
+		}
+	}
+}
+
+// newLogContext 构造写任务日志所需的context
+func newLogContext(jobId int32, runParam *JobRunParam) context.Context {
+	logParam := make(map[string]interface{})
+	logParam["logId"] = runParam.LogId
+	logParam["logDateTime"] = runParam.LogDateTime
+	logParam["jobId"] = jobId
+	logParam["jobName"] = runParam.JobName
+	logParam["jobFunc"] = runParam.JobTag
+
+	jobParam := make(map[string]map[string]interface{})
+	jobParam["logParam"] = logParam
+	return context.WithValue(context.Background(), "jobParam", jobParam)
 }
@@ -0,0 +1,538 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gongshen/xxl-job-client/constants"
+	"github.com/gongshen/xxl-job-client/logger"
+	"github.com/gongshen/xxl-job-client/queue"
+	"github.com/gongshen/xxl-job-client/transport"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetStore(logger.NewMemoryStore())
+	os.Exit(m.Run())
+}
+
+type callbackResult struct {
+	logId int64
+	err   error
+}
+
+func newTestJobHandler() (*JobHandler, chan callbackResult) {
+	results := make(chan callbackResult, 1024)
+	j := &JobHandler{
+		queueMap:      make(map[int32]*JobQueue),
+		QueueCapacity: 1000,
+		CallbackFunc: func(logId int64, _ int64, err error) {
+			results <- callbackResult{logId: logId, err: err}
+		},
+	}
+	return j, results
+}
+
+func newTrigger(jobId int32, logId int64, handler string) *transport.TriggerParam {
+	return &transport.TriggerParam{
+		JobId:                 jobId,
+		LogId:                 logId,
+		LogDateTime:           time.Now().UnixMilli(),
+		ExecutorHandler:       handler,
+		ExecutorBlockStrategy: constants.SerialExecution,
+		GlueType:              "BEAN",
+	}
+}
+
+func waitResult(t *testing.T, results chan callbackResult) callbackResult {
+	t.Helper()
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait job callback timeout")
+	}
+	return callbackResult{}
+}
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("wait condition timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPutJobToQueueConcurrent(t *testing.T) {
+	j, results := newTestJobHandler()
+	var runs int32
+	j.RegisterJob("count", func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+
+	const jobs, triggers = 8, 50
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for jobId := int32(1); jobId <= jobs; jobId++ {
+					j.HasRunning(jobId)
+				}
+				j.ActiveLogIds()
+				j.evictIdleQueue()
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for jobId := int32(1); jobId <= jobs; jobId++ {
+		for i := 0; i < triggers; i++ {
+			writers.Add(1)
+			go func(jobId int32, logId int64) {
+				defer writers.Done()
+				if err := j.PutJobToQueue(newTrigger(jobId, logId, "count")); err != nil {
+					t.Errorf("put job failed. logId:%d,err:%v", logId, err)
+				}
+			}(jobId, int64(jobId)*1000+int64(i))
+		}
+	}
+	writers.Wait()
+
+	seen := make(map[int64]bool)
+	for i := 0; i < jobs*triggers; i++ {
+		r := waitResult(t, results)
+		if r.err != nil {
+			t.Errorf("job failed. logId:%d,err:%v", r.logId, r.err)
+		}
+		if seen[r.logId] {
+			t.Errorf("duplicate callback. logId:%d", r.logId)
+		}
+		seen[r.logId] = true
+	}
+	close(stop)
+	readers.Wait()
+
+	if got := atomic.LoadInt32(&runs); got != jobs*triggers {
+		t.Fatalf("job runs %d, want %d", got, jobs*triggers)
+	}
+	j.clearJob()
+}
+
+func TestEvictIdleQueue(t *testing.T) {
+	j, results := newTestJobHandler()
+	j.IdleTimeout = 10 * time.Millisecond
+	j.RegisterJob("noop", func(ctx context.Context) error { return nil })
+
+	if err := j.PutJobToQueue(newTrigger(1, 1, "noop")); err != nil {
+		t.Fatal(err)
+	}
+	waitResult(t, results)
+	waitUntil(t, func() bool { return !j.HasRunning(1) })
+
+	time.Sleep(2 * j.IdleTimeout)
+	j.evictIdleQueue()
+	j.queueLock.RLock()
+	_, has := j.queueMap[1]
+	j.queueLock.RUnlock()
+	if has {
+		t.Fatal("idle job queue not evicted")
+	}
+
+	// 回收后再次调度重建任务线程
+	if err := j.PutJobToQueue(newTrigger(1, 2, "noop")); err != nil {
+		t.Fatal(err)
+	}
+	if r := waitResult(t, results); r.logId != 2 || r.err != nil {
+		t.Fatalf("unexpected result after evict: %+v", r)
+	}
+	j.clearJob()
+}
+
+func TestAutoEvictIdleQueueStopsOnExit(t *testing.T) {
+	j, _ := newTestJobHandler()
+	j.IdleTimeout = 10 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		j.AutoEvictIdleQueue()
+		close(done)
+	}()
+	j.clearJob()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("AutoEvictIdleQueue not stopped after clearJob")
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	j, results := newTestJobHandler()
+	started := make(chan struct{}, 1)
+	j.RegisterJob("block", func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := j.PutJobToQueue(newTrigger(1, 1, "block")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := j.PutJobToQueue(newTrigger(1, 2, "block")); err != nil {
+		t.Fatal(err)
+	}
+	if !j.HasRunning(1) {
+		t.Fatal("job should be running")
+	}
+
+	j.cancelJob(1)
+	for i := 0; i < 2; i++ {
+		r := waitResult(t, results)
+		if !IsKilled(r.err) {
+			t.Errorf("logId:%d want killed, got %v", r.logId, r.err)
+		}
+		if code, _ := HandleResult(r.err); code != HandleCodeFail {
+			t.Errorf("logId:%d handleCode %d, want %d", r.logId, code, HandleCodeFail)
+		}
+	}
+	if j.HasRunning(1) {
+		t.Fatal("job still running after cancel")
+	}
+	j.clearJob()
+}
+
+type lifecycleJob struct {
+	initPanic bool
+	destroyed chan struct{}
+}
+
+func (l *lifecycleJob) Init(ctx context.Context) error {
+	if l.initPanic {
+		panic("init boom")
+	}
+	return nil
+}
+
+func (l *lifecycleJob) Execute(ctx context.Context) error { return nil }
+
+func (l *lifecycleJob) Destroy(ctx context.Context) error {
+	close(l.destroyed)
+	panic("destroy boom")
+}
+
+func TestLifecyclePanicRecovered(t *testing.T) {
+	j, results := newTestJobHandler()
+	j.RegisterJob("init_panic", &lifecycleJob{initPanic: true, destroyed: make(chan struct{})})
+
+	if err := j.PutJobToQueue(newTrigger(1, 1, "init_panic")); err != nil {
+		t.Fatal(err)
+	}
+	r := waitResult(t, results)
+	var pe *PanicError
+	if !errors.As(r.err, &pe) {
+		t.Fatalf("want init panic error, got %v", r.err)
+	}
+	j.clearJob()
+}
+
+func TestClearJobWaitsDestroy(t *testing.T) {
+	j, results := newTestJobHandler()
+	job := &lifecycleJob{destroyed: make(chan struct{})}
+	j.RegisterJob("lifecycle", job)
+
+	if err := j.PutJobToQueue(newTrigger(1, 1, "lifecycle")); err != nil {
+		t.Fatal(err)
+	}
+	if r := waitResult(t, results); r.err != nil {
+		t.Fatal(r.err)
+	}
+	j.clearJob()
+	select {
+	case <-job.destroyed:
+	default:
+		t.Fatal("Destroy not called before clearJob returned")
+	}
+}
+
+func jobLog(logId int64) string {
+	return string(logger.GetStore().(*logger.MemoryStore).Bytes(logId))
+}
+
+func TestPutJobToQueueChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(trigger *transport.TriggerParam)
+		reason string
+	}{
+		{"handler", func(trigger *transport.TriggerParam) { trigger.ExecutorHandler = "new" }, "change jobhandler or glue type"},
+		{"glue type", func(trigger *transport.TriggerParam) { trigger.GlueType = "GLUE_GROOVY" }, "change jobhandler or glue type"},
+		{"glue source", func(trigger *transport.TriggerParam) { trigger.GlueUpdatetime = time.Now().UnixMilli() }, "change job source"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, results := newTestJobHandler()
+			started := make(chan struct{}, 1)
+			ran := make(chan string, 1)
+			oldLogId, newLogId := int64(2600+i*2), int64(2601+i*2)
+			register := func(name string) {
+				j.RegisterJob(name, func(ctx context.Context) error {
+					if info, _ := GetJobInfo(ctx); info.LogId == oldLogId {
+						started <- struct{}{}
+						<-ctx.Done()
+						return ctx.Err()
+					}
+					ran <- name
+					return nil
+				})
+			}
+			register("old")
+			register("new")
+
+			if err := j.PutJobToQueue(newTrigger(1, oldLogId, "old")); err != nil {
+				t.Fatal(err)
+			}
+			<-started
+			trigger := newTrigger(1, newLogId, "old")
+			tt.change(trigger)
+			if err := j.PutJobToQueue(trigger); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[int64]error)
+			for k := 0; k < 2; k++ {
+				r := waitResult(t, results)
+				got[r.logId] = r.err
+			}
+			if err, ok := got[oldLogId]; !ok || !IsKilled(err) {
+				t.Fatalf("old run want killed, got %v", err)
+			}
+			if err, ok := got[newLogId]; !ok || err != nil {
+				t.Fatalf("new run want success, got %v", err)
+			}
+			if name := <-ran; name != trigger.ExecutorHandler {
+				t.Fatalf("new run executed by %s, want %s", name, trigger.ExecutorHandler)
+			}
+			j.queueLock.RLock()
+			qu := j.queueMap[1]
+			j.queueLock.RUnlock()
+			if qu.ExecutorHandler != trigger.ExecutorHandler || qu.GlueType != trigger.GlueType || qu.GlueUpdatetime != trigger.GlueUpdatetime {
+				t.Fatalf("job queue not rebuilt: handler:%s,glueType:%s,glueUpdatetime:%d", qu.ExecutorHandler, qu.GlueType, qu.GlueUpdatetime)
+			}
+			if !strings.Contains(jobLog(newLogId), tt.reason) {
+				t.Fatalf("reason not in job log: %q", jobLog(newLogId))
+			}
+			waitUntil(t, func() bool { return strings.Contains(jobLog(oldLogId), tt.reason) })
+			j.clearJob()
+		})
+	}
+}
+
+func TestQueueDropOldestCallback(t *testing.T) {
+	j, results := newTestJobHandler()
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	j.RegisterJob("block", func(ctx context.Context) error {
+		if info, _ := GetJobInfo(ctx); info.LogId == 3001 {
+			started <- struct{}{}
+			<-release
+		}
+		return nil
+	}, WithQueueCapacity(1, queue.DropOldest))
+
+	if err := j.PutJobToQueue(newTrigger(1, 3001, "block")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	for _, logId := range []int64{3002, 3003} {
+		if err := j.PutJobToQueue(newTrigger(1, logId, "block")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 队列已满时丢弃最早的调度并回调admin
+	r := waitResult(t, results)
+	if r.logId != 3002 || !IsSkipped(r.err) {
+		t.Fatalf("want logId 3002 skipped, got %+v", r)
+	}
+	if code, msg := HandleResult(r.err); code != HandleCodeSuccess || !strings.Contains(msg, "discard the oldest trigger") {
+		t.Fatalf("dropped trigger callback code:%d,msg:%s", code, msg)
+	}
+	close(release)
+	for _, want := range []int64{3001, 3003} {
+		if r = waitResult(t, results); r.logId != want || r.err != nil {
+			t.Fatalf("want logId %d success, got %+v", want, r)
+		}
+	}
+	j.clearJob()
+}
+
+// eventLog 按顺序记录任务的生命周期及执行
+type eventLog struct {
+	sync.Mutex
+	events []string
+}
+
+func (e *eventLog) add(event string) {
+	e.Lock()
+	e.events = append(e.events, event)
+	e.Unlock()
+}
+
+func (e *eventLog) String() string {
+	e.Lock()
+	defer e.Unlock()
+	return strings.Join(e.events, ",")
+}
+
+type versionJob struct {
+	version    string
+	events     *eventLog
+	blockLogId int64 //执行该调度时阻塞到release关闭或任务被取消
+	started    chan struct{}
+	release    chan struct{}
+}
+
+func (v *versionJob) Init(ctx context.Context) error {
+	v.events.add(v.version + ":init")
+	return nil
+}
+
+func (v *versionJob) Execute(ctx context.Context) error {
+	info, _ := GetJobInfo(ctx)
+	v.events.add(fmt.Sprintf("%s:run:%d", v.version, info.LogId))
+	if info.LogId == v.blockLogId {
+		close(v.started)
+		select {
+		case <-v.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (v *versionJob) Destroy(ctx context.Context) error {
+	v.events.add(v.version + ":destroy")
+	return nil
+}
+
+func TestReplaceJob(t *testing.T) {
+	j, results := newTestJobHandler()
+	events := &eventLog{}
+	v1 := &versionJob{version: "v1", events: events, blockLogId: 3501, started: make(chan struct{}), release: make(chan struct{})}
+	j.RegisterJob("job", v1)
+
+	if err := j.PutJobToQueue(newTrigger(1, 3501, "job")); err != nil {
+		t.Fatal(err)
+	}
+	<-v1.started
+	if err := j.PutJobToQueue(newTrigger(1, 3502, "job")); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.ReplaceJob("job", &versionJob{version: "v2", events: events}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.PutJobToQueue(newTrigger(1, 3503, "job")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 正在执行的调度由旧任务执行完，队列中的调度转到新的任务线程，新任务在旧任务Destroy后Init
+	close(v1.release)
+	for i := 0; i < 3; i++ {
+		if r := waitResult(t, results); r.err != nil {
+			t.Fatalf("logId:%d,err:%v", r.logId, r.err)
+		}
+	}
+	want := "v1:init,v1:run:3501,v1:destroy,v2:init,v2:run:3502,v2:run:3503"
+	if got := events.String(); got != want {
+		t.Fatalf("events %s, want %s", got, want)
+	}
+	j.clearJob()
+	if got := events.String(); got != want+",v2:destroy" {
+		t.Fatalf("events after clearJob %s", got)
+	}
+}
+
+func TestUnregisterJob(t *testing.T) {
+	j, results := newTestJobHandler()
+	events := &eventLog{}
+	v1 := &versionJob{version: "v1", events: events, blockLogId: 3511, started: make(chan struct{}), release: make(chan struct{})}
+	j.RegisterJob("job", v1)
+
+	if err := j.PutJobToQueue(newTrigger(1, 3511, "job")); err != nil {
+		t.Fatal(err)
+	}
+	<-v1.started
+	if err := j.PutJobToQueue(newTrigger(1, 3512, "job")); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.UnregisterJob("job"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 注销后的调度及队列中等待的调度都返回任务不存在
+	if err := j.PutJobToQueue(newTrigger(1, 3513, "job")); err == nil || err.Error() != "bean job handler not found" {
+		t.Fatalf("trigger after unregister err %v", err)
+	}
+	if r := waitResult(t, results); r.logId != 3512 || r.err == nil || r.err.Error() != "bean job handler not found" {
+		t.Fatalf("pending trigger after unregister: %+v", r)
+	}
+
+	// 旧的任务线程仍可被退出时取消及等待，Destroy在clearJob返回前执行
+	j.clearJob()
+	if r := waitResult(t, results); r.logId != 3511 || !IsKilled(r.err) {
+		t.Fatalf("running job after clearJob: %+v", r)
+	}
+	if got, want := events.String(), "v1:init,v1:run:3511,v1:destroy"; got != want {
+		t.Fatalf("events %s, want %s", got, want)
+	}
+}
+
+func TestPanicRecoveredWithoutDefaultMiddleware(t *testing.T) {
+	j, results := newTestJobHandler()
+	j.DisableDefaultMiddleware = true
+	j.RegisterJob("panic", func(ctx context.Context) error {
+		if info, _ := GetJobInfo(ctx); info.LogId == 3601 {
+			panic("boom")
+		}
+		return nil
+	})
+
+	if err := j.PutJobToQueue(newTrigger(1, 3601, "panic")); err != nil {
+		t.Fatal(err)
+	}
+	r := waitResult(t, results)
+	var pe *PanicError
+	if !errors.As(r.err, &pe) || pe.Value != "boom" {
+		t.Fatalf("want panic error, got %v", r.err)
+	}
+	if !strings.Contains(jobLog(3601), "job panic: boom") {
+		t.Fatalf("panic not in job log: %q", jobLog(3601))
+	}
+
+	// 任务线程继续执行之后的调度
+	if err := j.PutJobToQueue(newTrigger(1, 3602, "panic")); err != nil {
+		t.Fatal(err)
+	}
+	if r = waitResult(t, results); r.logId != 3602 || r.err != nil {
+		t.Fatalf("job after panic: %+v", r)
+	}
+	j.clearJob()
+}
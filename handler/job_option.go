@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gongshen/xxl-job-client/queue"
+)
+
+type JobOption func(*JobOptions)
+
+// JobOptions 注册任务时的任务级配置
+type JobOptions struct {
+	//任务队列容量，小于等于0时使用执行器默认值
+	QueueCapacity int
+
+	//任务队列已满时的处理策略
+	OverflowPolicy queue.OverflowPolicy
+
+	//该任务所有jobId同时执行的最大数量，小于等于0时不限制
+	MaxConcurrency int
+
+	//任务执行时需要占用的共享资源，资源需先通过RegisterResource注册
+	Resources []string
+
+	//任务拦截器，在执行器全局拦截器之后执行
+	Middlewares []JobMiddleware
+
+	//执行器内重试策略，为空时不重试
+	Retry *RetryPolicy
+
+	err error //无效的配置，注册任务时返回
+}
+
+// jobDefine 注册的bean任务
+type jobDefine struct {
+	Handler LifecycleJobHandler
+	Options JobOptions
+
+	limit *Semaphore
+}
+
+func newJobOptions(opts ...JobOption) (JobOptions, error) {
+	options := JobOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options, options.err
+}
+
+// job queue capacity and the policy when the queue is full
+func WithQueueCapacity(capacity int, policy queue.OverflowPolicy) JobOption {
+	return func(o *JobOptions) {
+		o.QueueCapacity = capacity
+		o.OverflowPolicy = policy
+	}
+}
+
+// max number of runs of this job at the same time, across all jobIds bound to it, must be positive
+func WithMaxConcurrency(limit int) JobOption {
+	return func(o *JobOptions) {
+		if limit <= 0 {
+			o.err = fmt.Errorf("job max concurrency must be positive, limit:%d", limit)
+			return
+		}
+		o.MaxConcurrency = limit
+	}
+}
+
+// shared resources the job occupies while running, see JobHandler.RegisterResource
+func WithResources(names ...string) JobOption {
+	return func(o *JobOptions) {
+		o.Resources = append(o.Resources, names...)
+	}
+}
+
+// job middlewares, run after the executor global middlewares
+func WithMiddleware(middlewares ...JobMiddleware) JobOption {
+	return func(o *JobOptions) {
+		o.Middlewares = append(o.Middlewares, middlewares...)
+	}
+}
+
+// retry the job in this executor when it failed, only the final result is sent to admin
+func WithRetry(policy RetryPolicy) JobOption {
+	return func(o *JobOptions) {
+		o.Retry = &policy
+	}
+}
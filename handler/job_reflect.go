@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// lifecycleMethods LifecycleJobHandler的方法，names中指定任务名时才注册，避免生命周期方法被admin调度
+var lifecycleMethods = map[string]struct{}{
+	"Init":    {},
+	"Execute": {},
+	"Destroy": {},
+}
+
+// RegisterReport RegisterJobsFrom的注册结果
+type RegisterReport struct {
+	//方法名 -> 任务名
+	Registered map[string]string
+
+	//方法名 -> 跳过原因
+	Skipped map[string]string
+}
+
+// RegisterJobsFrom 将obj的导出方法注册为bean任务，支持 func(ctx context.Context) error 及 func(ctx context.Context)。
+// 任务名优先取names中方法名对应的值，值为空或"-"时跳过该方法；names中没有的方法使用首字母小写的方法名，如SyncOrder注册为syncOrder。
+// Init、Execute、Destroy只有在names中指定任务名时才注册。
+// 任务名重复时返回错误，不注册任何任务，返回的Registered为空
+func (j *JobHandler) RegisterJobsFrom(obj interface{}, names map[string]string, opts ...JobOption) (*RegisterReport, error) {
+	if obj == nil {
+		return nil, errors.New("register jobs from nil object")
+	}
+	report := &RegisterReport{
+		Registered: make(map[string]string),
+		Skipped:    make(map[string]string),
+	}
+	jobs := make(map[string]LifecycleJobHandler)
+	fail := func(err error) (*RegisterReport, error) {
+		report.Registered = make(map[string]string)
+		return report, err
+	}
+
+	val := reflect.ValueOf(obj)
+	typ := val.Type()
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		jobName, ok := names[method.Name]
+		if _, lifecycle := lifecycleMethods[method.Name]; lifecycle && !ok {
+			report.Skipped[method.Name] = "lifecycle method, register it by name map"
+			continue
+		}
+		if !ok {
+			jobName = lowerFirst(method.Name)
+		} else if jobName == "" || jobName == "-" {
+			report.Skipped[method.Name] = "excluded by name map"
+			continue
+		}
+
+		job, err := methodToJob(val.Method(i))
+		if err != nil {
+			report.Skipped[method.Name] = err.Error()
+			continue
+		}
+		report.Registered[method.Name] = jobName
+		jobs[jobName] = job
+	}
+
+	for methodName := range names {
+		if _, ok := typ.MethodByName(methodName); !ok {
+			return fail(fmt.Errorf("method %s not found in %s", methodName, typ))
+		}
+	}
+
+	defines := make(map[string]*jobDefine, len(jobs))
+	for jobName, job := range jobs {
+		define, err := newJobDefine(jobName, job, opts...)
+		if err != nil {
+			return fail(err)
+		}
+		defines[jobName] = define
+	}
+
+	j.Lock()
+	defer j.Unlock()
+	var duplicates []string
+	byName := make(map[string]string, len(report.Registered))
+	for methodName, jobName := range report.Registered {
+		if other, ok := byName[jobName]; ok {
+			duplicates = append(duplicates, fmt.Sprintf("%s(%s,%s)", jobName, other, methodName))
+		} else if j.hasJob(jobName) {
+			duplicates = append(duplicates, jobName+"(already registered)")
+		}
+		byName[jobName] = methodName
+	}
+	if len(duplicates) > 0 {
+		sort.Strings(duplicates)
+		return fail(errors.New("job name can't be repeated: " + strings.Join(duplicates, ", ")))
+	}
+	for jobName, define := range defines {
+		j.addJob(jobName, define)
+	}
+	return report, nil
+}
+
+// methodToJob 方法签名为 func(ctx context.Context) error 或 func(ctx context.Context) 时转换为任务
+func methodToJob(method reflect.Value) (LifecycleJobHandler, error) {
+	switch fn := method.Interface().(type) {
+	case func(context.Context) error:
+		return JobHandlerFunc(fn), nil
+	case func(context.Context):
+		return JobHandlerFunc(func(ctx context.Context) error {
+			fn(ctx)
+			return nil
+		}), nil
+	}
+
+	typ := method.Type()
+	if typ.NumIn() != 1 || typ.In(0) != contextType {
+		return nil, fmt.Errorf("unsupported signature %s, the only parameter must be context.Context", typ)
+	}
+	if typ.NumOut() > 1 || (typ.NumOut() == 1 && typ.Out(0) != errorType) {
+		return nil, fmt.Errorf("unsupported signature %s, the only result must be error", typ)
+	}
+	return nil, fmt.Errorf("unsupported signature %s", typ)
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type reflectJobs struct{}
+
+func (reflectJobs) SyncOrder(ctx context.Context) error { return nil }
+func (reflectJobs) Report(ctx context.Context)          {}
+func (reflectJobs) Cleanup(ctx context.Context) error   { return nil }
+func (reflectJobs) Internal(ctx context.Context) error  { return nil }
+func (reflectJobs) Helper(n int) error                  { return nil }
+func (reflectJobs) Count(ctx context.Context) int       { return 0 }
+func (reflectJobs) Init(ctx context.Context) error      { return nil }
+func (reflectJobs) Execute(ctx context.Context) error   { return nil }
+func (reflectJobs) Destroy(ctx context.Context) error   { return nil }
+
+func TestRegisterJobsFrom(t *testing.T) {
+	j, _ := newTestJobHandler()
+	report, err := j.RegisterJobsFrom(reflectJobs{}, map[string]string{
+		"Cleanup":  "cleanupJob",
+		"Internal": "-",
+		"Execute":  "reflectExecute",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registered := map[string]string{
+		"SyncOrder": "syncOrder",
+		"Report":    "report",
+		"Cleanup":   "cleanupJob",
+		"Execute":   "reflectExecute",
+	}
+	if len(report.Registered) != len(registered) {
+		t.Fatalf("registered %v, want %v", report.Registered, registered)
+	}
+	for method, jobName := range registered {
+		if report.Registered[method] != jobName {
+			t.Fatalf("method %s registered as %q, want %q", method, report.Registered[method], jobName)
+		}
+		if !j.hasJob(jobName) {
+			t.Fatalf("job %s not registered", jobName)
+		}
+	}
+
+	skipped := map[string]string{
+		"Internal": "excluded by name map",
+		"Helper":   "the only parameter must be context.Context",
+		"Count":    "the only result must be error",
+		"Init":     "lifecycle method",
+		"Destroy":  "lifecycle method",
+	}
+	if len(report.Skipped) != len(skipped) {
+		t.Fatalf("skipped %v, want %v", report.Skipped, skipped)
+	}
+	for method, reason := range skipped {
+		if !strings.Contains(report.Skipped[method], reason) {
+			t.Fatalf("method %s skipped reason %q, want %q", method, report.Skipped[method], reason)
+		}
+	}
+}
+
+func TestRegisterJobsFromDuplicate(t *testing.T) {
+	tests := []struct {
+		name  string
+		names map[string]string
+		want  string
+	}{
+		{"in object", map[string]string{"Cleanup": "syncOrder"}, "syncOrder("},
+		{"already registered", nil, "report(already registered)"},
+		{"method not found", map[string]string{"Missing": "missing"}, "method Missing not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, _ := newTestJobHandler()
+			j.RegisterJob("report", func(ctx context.Context) error { return nil })
+			report, err := j.RegisterJobsFrom(reflectJobs{}, tt.names)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err %v, want %s", err, tt.want)
+			}
+			if len(report.Registered) != 0 {
+				t.Fatalf("registered %v on error, want none", report.Registered)
+			}
+			if j.BeanJobLength() != 1 {
+				t.Fatalf("registered %d jobs on error, want only the existing one", j.BeanJobLength())
+			}
+		})
+	}
+}
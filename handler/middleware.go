@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gongshen/xxl-job-client/logger"
+)
+
+// JobMiddleware 任务拦截器，可在任务执行前后处理，如捕获panic、耗时统计、监控及链路追踪
+type JobMiddleware func(next JobHandlerFunc) JobHandlerFunc
+
+// JobInfo 本次执行的任务信息，拦截器及任务中通过GetJobInfo获取
+type JobInfo struct {
+	JobId       int32
+	LogId       int64
+	LogDateTime int64
+	JobName     string
+	JobTag      string
+	InputParam  map[string]interface{}
+	ShardIdx    int32
+	ShardTotal  int32
+}
+
+type jobInfoKey struct{}
+
+// GetJobInfo 获取当前执行的任务信息
+func GetJobInfo(ctx context.Context) (*JobInfo, bool) {
+	info, ok := ctx.Value(jobInfoKey{}).(*JobInfo)
+	return info, ok
+}
+
+func withJobInfo(ctx context.Context, info *JobInfo) context.Context {
+	return context.WithValue(ctx, jobInfoKey{}, info)
+}
+
+// chainMiddleware 按顺序组装拦截器，第一个拦截器在最外层
+func chainMiddleware(job JobHandlerFunc, middlewares ...JobMiddleware) JobHandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		job = middlewares[i](job)
+	}
+	return job
+}
+
+// RecoverConfig panic捕获配置
+type RecoverConfig struct {
+	//堆栈最大字节数，小于等于0时使用defaultMaxStackSize
+	MaxStackSize int
+
+	//不为空时堆栈同时输出到应用日志
+	Logger interface {
+		Printf(format string, v ...interface{})
+	}
+}
+
+// NewRecoverMiddleware 按配置捕获任务panic并转换为PanicError，堆栈写入任务日志
+func NewRecoverMiddleware(cfg RecoverConfig) JobMiddleware {
+	return func(next JobHandlerFunc) JobHandlerFunc {
+		return func(ctx context.Context) (err error) {
+			defer func() {
+				r := recover()
+				if r != nil {
+					pe := newPanicError(r, cfg.MaxStackSize)
+					logger.Error(ctx, "job panic: ", fmt.Sprintf("%v", r), "\r\n", pe.Stack)
+					if cfg.Logger != nil {
+						info, _ := GetJobInfo(ctx)
+						if info == nil {
+							info = &JobInfo{}
+						}
+						cfg.Logger.Printf("job panic. jobId:%d,logId:%d,jobName:%s,panic:%v\n%s", info.JobId, info.LogId, info.JobName, r, pe.Stack)
+					}
+					err = pe
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// LoggingMiddleware 任务执行失败时记录任务日志
+func LoggingMiddleware(next JobHandlerFunc) JobHandlerFunc {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		err := next(ctx)
+		if isFailure(err) {
+			logger.Error(ctx, "job run failed! msg:", err.Error(), ", cost:", time.Since(start))
+		} else if err != nil {
+			logger.Info(ctx, "job run end. msg:", err.Error(), ", cost:", time.Since(start))
+		}
+		return err
+	}
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDefaultMiddlewaresUseRecoverConfig(t *testing.T) {
+	j := &JobHandler{Recover: RecoverConfig{MaxStackSize: 1}}
+	job := chainMiddleware(func(ctx context.Context) error {
+		panic("boom")
+	}, j.DefaultMiddlewares()...)
+
+	var pe *PanicError
+	if err := job(context.Background()); !errors.As(err, &pe) {
+		t.Fatalf("want PanicError, got %v", err)
+	}
+	if !strings.HasSuffix(pe.Stack, "...stack truncated\r\n") || len(pe.Stack) > 64 {
+		t.Fatalf("stack not limited by RecoverConfig: %q", pe.Stack)
+	}
+}
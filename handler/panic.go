@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const defaultMaxStackSize = 8 * 1024
+
+// PanicError 任务panic，Error只返回panic值及发生位置，完整堆栈在Stack中
+type PanicError struct {
+	Value    interface{}
+	Location string //发生panic的位置 file:line
+	Stack    string //去除runtime帧后的堆栈
+}
+
+func (e *PanicError) Error() string {
+	if e.Location == "" {
+		return fmt.Sprintf("panic:%v", e.Value)
+	}
+	return fmt.Sprintf("panic:%v at %s", e.Value, e.Location)
+}
+
+// newPanicError 在recover的defer中调用，获取发生panic的协程堆栈
+func newPanicError(value interface{}, maxSize int) *PanicError {
+	if maxSize <= 0 {
+		maxSize = defaultMaxStackSize
+	}
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	pe := &PanicError{Value: value}
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			if pe.Location == "" {
+				pe.Location = fmt.Sprintf("%s:%d", frame.File, frame.Line)
+			}
+			line := fmt.Sprintf("%s\r\n\t%s:%d\r\n", frame.Function, frame.File, frame.Line)
+			if sb.Len()+len(line) > maxSize {
+				sb.WriteString("...stack truncated\r\n")
+				break
+			}
+			sb.WriteString(line)
+		}
+		if !more {
+			break
+		}
+	}
+	pe.Stack = sb.String()
+	return pe
+}
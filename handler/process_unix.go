@@ -0,0 +1,16 @@
+//go:build !windows
+
+package handler
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 脚本在独立的进程组中执行，取消时结束脚本及其启动的所有子进程
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package handler
+
+import "os/exec"
+
+// setProcessGroup windows下取消时只结束脚本进程，子进程持有的输出由WaitDelay兜底
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -7,7 +7,14 @@ import (
 	"log"
 )
 
-type HttpRequestHandler struct{}
+type HttpRequestHandler struct {
+	//每次读取日志的最大行数及字节数，小于等于0时不限制
+	LogMaxLines int
+	LogMaxBytes int
+
+	//调度是否正在执行或等待执行，正在执行时日志未结束
+	IsActive func(logId int64) bool
+}
 
 func (h HttpRequestHandler) Beat() error {
 	return nil
@@ -49,12 +56,15 @@ func (h HttpRequestHandler) Log(body []byte) (log *logger.LogResult, err error)
 	if err != nil {
 		return nil, err
 	}
-	line, content := logger.ReadLog(lq.LogDateTim, lq.LogId, lq.FromLineNum)
+	line, content, eof := logger.ReadLogPage(lq.LogDateTim, lq.LogId, lq.FromLineNum, h.LogMaxLines, h.LogMaxBytes)
 	log = &logger.LogResult{
 		FromLineNum: lq.FromLineNum,
 		ToLineNum:   line,
 		LogContent:  content,
-		IsEnd:       true,
+		IsEnd:       eof,
+	}
+	if eof && h.IsActive != nil && h.IsActive(lq.LogId) {
+		log.IsEnd = false
 	}
 	return log, err
 }
@@ -2,15 +2,21 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/valyala/fasthttp"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/gongshen/xxl-job-client/admin"
+	"github.com/gongshen/xxl-job-client/logger"
 	"github.com/gongshen/xxl-job-client/transport"
 )
 
+// IdleChecker 自定义idleBeat忙碌检查，忙碌时返回true及原因，admin忙碌转移路由策略会跳过忙碌的执行器
+type IdleChecker func(jobId int32) (busy bool, reason string)
+
 type RequestProcess struct {
 	sync.RWMutex
 
@@ -19,6 +25,13 @@ type RequestProcess struct {
 	JobHandler *JobHandler
 
 	ReqHandler *HttpRequestHandler
+
+	// 调度去重，为空时不去重
+	Dedup *TriggerDedup
+
+	idleCheckers []IdleChecker
+
+	draining int32 //1 摘流中，不再接收新的调度
 }
 
 func NewRequestProcess(adminServer *admin.XxlAdminServer, handler *HttpRequestHandler) *RequestProcess {
@@ -27,15 +40,92 @@ func NewRequestProcess(adminServer *admin.XxlAdminServer, handler *HttpRequestHa
 		ReqHandler:  handler,
 	}
 	jobHandler := &JobHandler{
-		QueueMap:     make(map[int32]*JobQueue),
+		queueMap:     make(map[int32]*JobQueue),
 		CallbackFunc: requestHandler.jobRunCallback,
 	}
 	requestHandler.JobHandler = jobHandler
+	if handler.IsActive == nil {
+		handler.IsActive = jobHandler.IsLogActive
+	}
 	return requestHandler
 }
 
-func (r *RequestProcess) RegisterJob(jobName string, function JobHandlerFunc) {
-	r.JobHandler.RegisterJob(jobName, function)
+func (r *RequestProcess) RegisterJob(jobName string, job interface{}, opts ...JobOption) {
+	r.JobHandler.RegisterJob(jobName, job, opts...)
+}
+
+// AddIdleChecker 添加自定义idleBeat忙碌检查，在内置的任务运行检查之后依次执行
+func (r *RequestProcess) AddIdleChecker(checker IdleChecker) {
+	r.Lock()
+	defer r.Unlock()
+	r.idleCheckers = append(r.idleCheckers, checker)
+}
+
+// Drain 进入摘流模式：idleBeat返回忙碌，拒绝新的调度，正在执行的任务继续执行。deregister为true时同时移除admin注册
+func (r *RequestProcess) Drain(deregister bool) {
+	if atomic.CompareAndSwapInt32(&r.draining, 0, 1) {
+		log.Print("executor draining")
+		if deregister {
+			r.adminServer.PauseRegister()
+		}
+	}
+}
+
+// Undrain 退出摘流模式，摘流时移除了admin注册的重新注册
+func (r *RequestProcess) Undrain() {
+	if atomic.CompareAndSwapInt32(&r.draining, 1, 0) {
+		log.Print("executor undrained")
+		r.adminServer.ResumeRegister()
+	}
+}
+
+func (r *RequestProcess) IsDraining() bool {
+	return atomic.LoadInt32(&r.draining) == 1
+}
+
+// idleBeat 检查执行器是否忙碌，任一检查忙碌即返回
+func (r *RequestProcess) idleBeat(jobId int32) (busy bool, reason string) {
+	if r.IsDraining() {
+		return true, "executor is draining"
+	}
+	if r.JobHandler.HasRunning(jobId) {
+		return true, "job is running or has trigger queued"
+	}
+	if r.JobHandler.Saturated() {
+		return true, "worker pool is saturated"
+	}
+	r.RLock()
+	defer r.RUnlock()
+	for _, checker := range r.idleCheckers {
+		if busy, reason = checker(jobId); busy {
+			return busy, reason
+		}
+	}
+	return false, ""
+}
+
+func (r *RequestProcess) Use(middlewares ...JobMiddleware) {
+	r.JobHandler.Use(middlewares...)
+}
+
+func (r *RequestProcess) DefaultMiddlewares() []JobMiddleware {
+	return r.JobHandler.DefaultMiddlewares()
+}
+
+func (r *RequestProcess) UnregisterJob(jobName string) error {
+	return r.JobHandler.UnregisterJob(jobName)
+}
+
+func (r *RequestProcess) ReplaceJob(jobName string, job interface{}, opts ...JobOption) error {
+	return r.JobHandler.ReplaceJob(jobName, job, opts...)
+}
+
+func (r *RequestProcess) RegisterJobsFrom(obj interface{}, names map[string]string, opts ...JobOption) (*RegisterReport, error) {
+	return r.JobHandler.RegisterJobsFrom(obj, names, opts...)
+}
+
+func (r *RequestProcess) RegisterResource(name string, limit int) {
+	r.JobHandler.RegisterResource(name, limit)
 }
 
 func (r *RequestProcess) pushJob(trigger *transport.TriggerParam) {
@@ -46,32 +136,29 @@ func (r *RequestProcess) pushJob(trigger *transport.TriggerParam) {
 		callback := &transport.HandleCallbackParam{
 			LogId:      trigger.LogId,
 			LogDateTim: trigger.LogDateTime,
-			Code:       http.StatusInternalServerError,
-			Msg:        err.Error(),
-		}
-		if ne, ok := err.(interface{ Temporary() bool }); ok && ne.Temporary() {
-			callback.Code = http.StatusOK
 		}
+		callback.Code, callback.Msg = HandleResult(err)
 
 		r.adminServer.CallbackAdmin([]*transport.HandleCallbackParam{callback})
 	}
 }
 
+func (r *RequestProcess) duplicateTrigger(trigger *transport.TriggerParam) {
+	log.Printf("duplicate trigger ignored. jobId:%d,logId:%d\n", trigger.JobId, trigger.LogId)
+	runParam := &JobRunParam{
+		LogId:       trigger.LogId,
+		LogDateTime: trigger.LogDateTime,
+		JobName:     trigger.ExecutorHandler,
+	}
+	logger.Warn(newLogContext(trigger.JobId, runParam), fmt.Sprintf("duplicate trigger, logId:%d had already received, ignored.", trigger.LogId))
+}
+
 func (r *RequestProcess) jobRunCallback(logId, logDatetime int64, runErr error) {
 	callback := &transport.HandleCallbackParam{
 		LogId:      logId,
 		LogDateTim: logDatetime,
-		Code:       http.StatusOK,
-		Msg:        "success",
-	}
-	if runErr != nil {
-		if ne, ok := runErr.(interface{ Temporary() bool }); !ok || !ne.Temporary() {
-			callback.Code = http.StatusInternalServerError
-			callback.Msg = runErr.Error()
-		} else {
-			callback.Msg = runErr.Error()
-		}
 	}
+	callback.Code, callback.Msg = HandleResult(runErr)
 	r.adminServer.CallbackAdmin([]*transport.HandleCallbackParam{callback})
 }
 
@@ -88,9 +175,12 @@ func (r *RequestProcess) RequestProcess(ctx *fasthttp.RequestCtx) {
 			returnt.Code = http.StatusInternalServerError
 			returnt.Msg = err.Error()
 		} else {
-			if r.JobHandler.HasRunning(jobId) {
+			if busy, reason := r.idleBeat(jobId); busy {
 				returnt.Code = http.StatusInternalServerError
 				returnt.Msg = "the server busy"
+				if reason != "" {
+					returnt.Msg += ", " + reason
+				}
 			}
 		}
 	case "/log":
@@ -115,8 +205,16 @@ func (r *RequestProcess) RequestProcess(ctx *fasthttp.RequestCtx) {
 			log.Printf("PushJob. triggerParams: %+v\n", ta)
 			returnt.Code = http.StatusInternalServerError
 			returnt.Msg = err.Error()
+		} else if r.IsDraining() {
+			// 摘流中拒绝调度，admin按失败处理并转移到其他执行器
+			returnt.Code = http.StatusInternalServerError
+			returnt.Msg = "executor is draining, please retry later"
+		} else if r.Dedup != nil && r.Dedup.Seen(ta.LogId) {
+			// 重复调度直接返回成功，不再执行
+			go r.duplicateTrigger(ta)
+		} else {
+			go r.pushJob(ta)
 		}
-		go r.pushJob(ta)
 	}
 
 	bytes, _ := json.Marshal(&returnt)
@@ -127,9 +225,13 @@ func (r *RequestProcess) RequestProcess(ctx *fasthttp.RequestCtx) {
 func (r *RequestProcess) RemoveRegisterExecutor() {
 	r.JobHandler.clearJob()
 	r.adminServer.RemoveRegisterExecutor()
+	if r.Dedup != nil {
+		r.Dedup.Close()
+	}
 }
 
 func (r *RequestProcess) RegisterExecutor() {
 	r.adminServer.RegisterExecutor()
 	go r.adminServer.AutoRegisterJobGroup()
+	go r.JobHandler.AutoEvictIdleQueue()
 }
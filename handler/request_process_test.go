@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/gongshen/xxl-job-client/admin"
+	"github.com/gongshen/xxl-job-client/transport"
+)
+
+func newTestRequestProcess() (*RequestProcess, chan callbackResult) {
+	adminServer := admin.NewAdminServer([]string{"http://127.0.0.1:0"}, time.Second, 10*time.Second, nil)
+	r := NewRequestProcess(adminServer, &HttpRequestHandler{})
+	results := make(chan callbackResult, 16)
+	r.JobHandler.QueueCapacity = 10
+	r.JobHandler.CallbackFunc = func(logId int64, _ int64, err error) {
+		results <- callbackResult{logId: logId, err: err}
+	}
+	return r, results
+}
+
+// request 模拟admin请求执行器接口
+func request(t *testing.T, r *RequestProcess, path string, body interface{}) transport.ReturnT {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.SetBody(data)
+	r.RequestProcess(ctx)
+
+	var returnt transport.ReturnT
+	if err = json.Unmarshal(ctx.Response.Body(), &returnt); err != nil {
+		t.Fatal(err)
+	}
+	return returnt
+}
+
+func TestDrain(t *testing.T) {
+	r, results := newTestRequestProcess()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.RegisterJob("block", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	if ret := request(t, r, "/run", newTrigger(1, 3400, "block")); ret.Code != http.StatusOK {
+		t.Fatalf("run before drain: %+v", ret)
+	}
+	<-started
+
+	// 摘流后拒绝调度，idleBeat返回忙碌
+	r.Drain(true)
+	if ret := request(t, r, "/run", newTrigger(2, 3401, "block")); ret.Code != http.StatusInternalServerError || !strings.Contains(ret.Msg, "draining") {
+		t.Fatalf("run while draining: %+v", ret)
+	}
+	if ret := request(t, r, "/idleBeat", JobId{JobId: 2}); ret.Code != http.StatusInternalServerError || !strings.Contains(ret.Msg, "executor is draining") {
+		t.Fatalf("idleBeat while draining: %+v", ret)
+	}
+
+	// 正在执行的任务继续执行
+	close(release)
+	if res := waitResult(t, results); res.logId != 3400 || res.err != nil {
+		t.Fatalf("in-flight job while draining: %+v", res)
+	}
+	select {
+	case res := <-results:
+		t.Fatalf("rejected trigger executed: %+v", res)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r.Undrain()
+	if ret := request(t, r, "/idleBeat", JobId{JobId: 2}); ret.Code != http.StatusOK {
+		t.Fatalf("idleBeat after undrain: %+v", ret)
+	}
+	r.JobHandler.clearJob()
+}
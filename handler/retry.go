@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gongshen/xxl-job-client/logger"
+)
+
+// RetryPolicy 执行器内重试策略，重试在同一次调度内进行，每次重试记录在同一个任务日志中，只有最终结果回调admin。
+// 每次执行的panic转换为*PanicError，与其他错误一样按Retryable判断是否重试
+type RetryPolicy struct {
+	//最大执行次数，包含首次执行
+	MaxAttempts int
+
+	//首次重试间隔
+	Backoff time.Duration
+
+	//每次重试间隔的增长倍数，小于等于1时间隔固定
+	Multiplier float64
+
+	//最大重试间隔，小于等于0时不限制
+	MaxBackoff time.Duration
+
+	//判断错误是否可重试，为空时所有错误都重试；任务panic时err为*PanicError
+	Retryable func(err error) bool
+}
+
+// RetryOn 错误匹配targets中任一错误时可重试，使用errors.Is判断
+func RetryOn(targets ...error) func(err error) bool {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IsTemporary 错误实现了Temporary() bool且为临时错误时可重试
+func IsTemporary(err error) bool {
+	var te interface{ Temporary() bool }
+	return errors.As(err, &te) && te.Temporary()
+}
+
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry && p.Multiplier > 1; i++ {
+		d = time.Duration(float64(d) * p.Multiplier)
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// middleware 按重试策略执行任务，任务被取消时不再重试
+func (p *RetryPolicy) middleware(next JobHandlerFunc) JobHandlerFunc {
+	return func(ctx context.Context) error {
+		attempt := 1
+		err := next(ctx)
+		for ; isFailure(err) && attempt < p.MaxAttempts && ctx.Err() == nil; attempt++ {
+			if p.Retryable != nil && !p.Retryable(err) {
+				logger.Warn(ctx, fmt.Sprintf("job run failed at attempt %d/%d, error not retryable. msg:%s", attempt, p.MaxAttempts, err.Error()))
+				return err
+			}
+
+			wait := p.backoff(attempt)
+			logger.Warn(ctx, fmt.Sprintf("job run failed at attempt %d/%d, retry after %s. msg:%s", attempt, p.MaxAttempts, wait, err.Error()))
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			err = next(ctx)
+		}
+		if !isFailure(err) {
+			if attempt > 1 {
+				logger.Info(ctx, fmt.Sprintf("job run success at attempt %d/%d", attempt, p.MaxAttempts))
+			}
+			return err
+		}
+		if attempt > 1 {
+			return fmt.Errorf("job run failed after %d attempts: %w", attempt, err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{Backoff: 10 * time.Millisecond, Multiplier: 2, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w*time.Millisecond {
+			t.Fatalf("backoff of retry %d is %s, want %s", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	// 倍数小于等于1时间隔固定
+	p = &RetryPolicy{Backoff: 10 * time.Millisecond, Multiplier: 1}
+	if got := p.backoff(5); got != 10*time.Millisecond {
+		t.Fatalf("fixed backoff %s, want 10ms", got)
+	}
+}
+
+// failingJob 前fails次执行返回err
+func failingJob(fails int, err error, attempts *int) JobHandlerFunc {
+	return func(ctx context.Context) error {
+		*attempts++
+		if *attempts <= fails {
+			return err
+		}
+		return nil
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	errTemp := errors.New("temporary")
+	errFatal := errors.New("fatal")
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		fails    int
+		err      error
+		attempts int
+		success  bool
+	}{
+		{"success after retry", RetryPolicy{MaxAttempts: 3}, 2, errTemp, 3, true},
+		{"max attempts", RetryPolicy{MaxAttempts: 3}, 5, errTemp, 3, false},
+		{"retryable veto", RetryPolicy{MaxAttempts: 3, Retryable: RetryOn(errTemp)}, 5, errFatal, 1, false},
+		{"retryable match", RetryPolicy{MaxAttempts: 3, Retryable: RetryOn(errTemp)}, 1, errTemp, 2, true},
+		{"skipped not retried", RetryPolicy{MaxAttempts: 3}, 5, Skipped("skip"), 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			err := tt.policy.middleware(failingJob(tt.fails, tt.err, &attempts))(context.Background())
+			if attempts != tt.attempts {
+				t.Fatalf("attempts %d, want %d", attempts, tt.attempts)
+			}
+			if (err == nil) != tt.success {
+				t.Fatalf("err %v, want success:%v", err, tt.success)
+			}
+			if err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("err %v does not wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 5, Backoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts int
+	done := make(chan error)
+	go func() {
+		done <- p.middleware(failingJob(5, errors.New("failed"), &attempts))(ctx)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil || attempts != 1 {
+			t.Fatalf("attempts %d,err %v, want 1 failed attempt", attempts, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retry not stopped after cancel")
+	}
+}
+
+func TestRetryPanic(t *testing.T) {
+	j, results := newTestJobHandler()
+	var attempts int
+	j.RegisterJob("panic", func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			panic("boom")
+		}
+		return nil
+	}, WithRetry(RetryPolicy{MaxAttempts: 3}))
+
+	if err := j.PutJobToQueue(newTrigger(1, 3701, "panic")); err != nil {
+		t.Fatal(err)
+	}
+	if r := waitResult(t, results); r.err != nil || attempts != 3 {
+		t.Fatalf("panic not retried. attempts:%d,err:%v", attempts, r.err)
+	}
+
+	// Retryable可以判断panic是否重试
+	var vetoed error
+	j.RegisterJob("veto", func(ctx context.Context) error {
+		panic("boom")
+	}, WithRetry(RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool {
+		vetoed = err
+		return false
+	}}))
+	if err := j.PutJobToQueue(newTrigger(2, 3702, "veto")); err != nil {
+		t.Fatal(err)
+	}
+	r := waitResult(t, results)
+	var pe *PanicError
+	if !errors.As(vetoed, &pe) || !errors.As(r.err, &pe) {
+		t.Fatalf("Retryable got %v, result %v, want panic error", vetoed, r.err)
+	}
+	j.clearJob()
+}
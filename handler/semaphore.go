@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Semaphore 并发限制，用于执行器全局、单个任务及共享资源的并发控制
+type Semaphore struct {
+	Name  string
+	slots chan struct{}
+}
+
+func NewSemaphore(name string, limit int) *Semaphore {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &Semaphore{
+		Name:  name,
+		slots: make(chan struct{}, limit),
+	}
+}
+
+func (s *Semaphore) TryAcquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Acquire 获取一个并发名额，没有空闲名额时阻塞直到ctx结束
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Semaphore) Release() {
+	<-s.slots
+}
+
+// Saturated 并发名额是否已用完
+func (s *Semaphore) Saturated() bool {
+	return len(s.slots) >= cap(s.slots)
+}
+
+func (s *Semaphore) String() string {
+	return fmt.Sprintf("%s(%d/%d)", s.Name, len(s.slots), cap(s.slots))
+}
+
+// sortSemaphores 按名称排序，保证所有任务按相同顺序获取名额，避免死锁
+func sortSemaphores(sems []*Semaphore) {
+	sort.Slice(sems, func(i, k int) bool {
+		return sems[i].Name < sems[k].Name
+	})
+}
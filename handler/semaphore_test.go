@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyJob 记录同时执行的最大数量
+type concurrencyJob struct {
+	running int32
+	max     int32
+}
+
+func (c *concurrencyJob) run(ctx context.Context) error {
+	n := atomic.AddInt32(&c.running, 1)
+	defer atomic.AddInt32(&c.running, -1)
+	for {
+		m := atomic.LoadInt32(&c.max)
+		if n <= m || atomic.CompareAndSwapInt32(&c.max, m, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return nil
+}
+
+func TestJobMaxConcurrency(t *testing.T) {
+	j, results := newTestJobHandler()
+	job := &concurrencyJob{}
+	j.RegisterJob("limited", job.run, WithMaxConcurrency(1))
+
+	// 同一任务绑定的多个jobId共享并发限制
+	for jobId := int32(1); jobId <= 3; jobId++ {
+		if err := j.PutJobToQueue(newTrigger(jobId, 3100+int64(jobId), "limited")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if r := waitResult(t, results); r.err != nil {
+			t.Fatal(r.err)
+		}
+	}
+	if max := atomic.LoadInt32(&job.max); max != 1 {
+		t.Fatalf("max concurrency %d, want 1", max)
+	}
+	j.clearJob()
+}
+
+func TestSharedResource(t *testing.T) {
+	j, results := newTestJobHandler()
+	j.RegisterResource("db", 1)
+	job := &concurrencyJob{}
+	j.RegisterJob("a", job.run, WithResources("db"))
+	j.RegisterJob("b", job.run, WithResources("db"))
+
+	for i := int64(0); i < 3; i++ {
+		if err := j.PutJobToQueue(newTrigger(1, 3200+i, "a")); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.PutJobToQueue(newTrigger(2, 3210+i, "b")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		if r := waitResult(t, results); r.err != nil {
+			t.Fatal(r.err)
+		}
+	}
+	if max := atomic.LoadInt32(&job.max); max != 1 {
+		t.Fatalf("max concurrency of shared resource %d, want 1", max)
+	}
+	j.clearJob()
+}
+
+func TestSaturatedIdleBeat(t *testing.T) {
+	j, results := newTestJobHandler()
+	j.WorkerPool = NewSemaphore("executor", 1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	j.RegisterJob("block", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	r := &RequestProcess{JobHandler: j}
+
+	if busy, reason := r.idleBeat(2); busy {
+		t.Fatalf("idle executor reported busy: %s", reason)
+	}
+	if err := j.PutJobToQueue(newTrigger(1, 3300, "block")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if busy, reason := r.idleBeat(2); !busy || reason != "worker pool is saturated" {
+		t.Fatalf("saturated executor idleBeat busy:%v,reason:%s", busy, reason)
+	}
+	close(release)
+	waitResult(t, results)
+	if busy, reason := r.idleBeat(2); busy {
+		t.Fatalf("executor still busy after job finished: %s", reason)
+	}
+	j.clearJob()
+}
+
+func TestInvalidLimitRejected(t *testing.T) {
+	j, _ := newTestJobHandler()
+	mustPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("%s with non-positive limit should panic", name)
+			}
+		}()
+		fn()
+	}
+	mustPanic("RegisterResource", func() { j.RegisterResource("db", 0) })
+	mustPanic("RegisterJob", func() {
+		j.RegisterJob("zero", func(ctx context.Context) error { return nil }, WithMaxConcurrency(0))
+	})
+
+	j.RegisterJob("job", func(ctx context.Context) error { return nil })
+	if err := j.ReplaceJob("job", func(ctx context.Context) error { return nil }, WithMaxConcurrency(-1)); err == nil {
+		t.Fatal("ReplaceJob with non-positive max concurrency should fail")
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// TriggerDedup 记录最近收到的调度LogId，用于过滤admin重复下发的调度
+type TriggerDedup struct {
+	sync.Mutex
+	size    int
+	seen    map[int64]struct{}
+	window  []int64 //按收到顺序记录LogId，超过size时淘汰最早的
+	next    int
+	file    *os.File //持久化文件，为空时只保存在内存
+	path    string
+	written int
+}
+
+// NewTriggerDedup 创建调度去重窗口，path不为空时LogId持久化到该文件，重启后仍然生效
+func NewTriggerDedup(size int, path string) (*TriggerDedup, error) {
+	if size <= 0 {
+		size = 1
+	}
+	d := &TriggerDedup{
+		size:   size,
+		seen:   make(map[int64]struct{}, size),
+		window: make([]int64, 0, size),
+		path:   path,
+	}
+	if path == "" {
+		return d, nil
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	if err := d.compact(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Seen 判断LogId是否已经收到过，没有收到过时记录该LogId
+func (d *TriggerDedup) Seen(logId int64) bool {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.seen[logId]; ok {
+		return true
+	}
+	d.add(logId)
+	if d.file != nil {
+		if _, err := fmt.Fprintf(d.file, "%d\n", logId); err != nil {
+			log.Printf("persist trigger logId failed. logId:%d,err:%v\n", logId, err)
+		}
+		d.written++
+		if d.written >= 2*d.size {
+			if err := d.compact(); err != nil {
+				log.Printf("compact trigger logId file failed. err:%v\n", err)
+			}
+		}
+	}
+	return false
+}
+
+func (d *TriggerDedup) add(logId int64) {
+	if len(d.window) < d.size {
+		d.window = append(d.window, logId)
+	} else {
+		delete(d.seen, d.window[d.next])
+		d.window[d.next] = logId
+		d.next = (d.next + 1) % d.size
+	}
+	d.seen[logId] = struct{}{}
+}
+
+// ordered 按收到顺序返回窗口中的LogId
+func (d *TriggerDedup) ordered() []int64 {
+	ids := make([]int64, 0, len(d.window))
+	ids = append(ids, d.window[d.next:]...)
+	return append(ids, d.window[:d.next]...)
+}
+
+func (d *TriggerDedup) load() error {
+	file, err := os.Open(d.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		logId, err := strconv.ParseInt(scanner.Text(), 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := d.seen[logId]; !ok {
+			d.add(logId)
+		}
+	}
+	return scanner.Err()
+}
+
+// compact 只保留窗口内的LogId重写持久化文件，避免文件无限增长
+func (d *TriggerDedup) compact() error {
+	tmpPath := d.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, logId := range d.ordered() {
+		fmt.Fprintf(w, "%d\n", logId)
+	}
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, d.path); err != nil {
+		return err
+	}
+
+	if d.file != nil {
+		d.file.Close()
+	}
+	d.file, err = os.OpenFile(d.path, os.O_WRONLY|os.O_APPEND, 0644)
+	d.written = 0
+	return err
+}
+
+func (d *TriggerDedup) Close() error {
+	d.Lock()
+	defer d.Unlock()
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
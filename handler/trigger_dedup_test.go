@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTriggerDedupWindow(t *testing.T) {
+	d, err := NewTriggerDedup(3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for logId := int64(1); logId <= 3; logId++ {
+		if d.Seen(logId) {
+			t.Fatalf("logId %d seen before received", logId)
+		}
+	}
+	if !d.Seen(2) {
+		t.Fatal("duplicate logId 2 not detected")
+	}
+
+	// 超过窗口大小时淘汰最早的LogId
+	d.Seen(4)
+	if d.Seen(1) {
+		t.Fatal("logId 1 should be evicted from the window")
+	}
+	if !d.Seen(4) || !d.Seen(3) {
+		t.Fatal("logIds in the window should be detected")
+	}
+}
+
+func TestTriggerDedupLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup")
+	d, err := NewTriggerDedup(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Seen(1)
+	d.Seen(2)
+	d.Seen(3)
+	d.Close()
+
+	// 重启后从持久化文件加载窗口内的LogId
+	d, err = NewTriggerDedup(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if !d.Seen(2) || !d.Seen(3) {
+		t.Fatal("persisted logIds not loaded after restart")
+	}
+	if d.Seen(1) {
+		t.Fatal("logId evicted before restart should not be loaded")
+	}
+}
+
+func TestTriggerDedupCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup")
+	d, err := NewTriggerDedup(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for logId := int64(1); logId <= 3; logId++ {
+		d.Seen(logId)
+	}
+	if lines := readDedupFile(t, path); len(lines) != 3 {
+		t.Fatalf("persisted %v before compact, want 3 logIds", lines)
+	}
+
+	// 写入次数达到窗口的2倍时只保留窗口内的LogId
+	d.Seen(4)
+	lines := readDedupFile(t, path)
+	if len(lines) != 2 || lines[0] != "3" || lines[1] != "4" {
+		t.Fatalf("persisted %v after compact, want [3 4]", lines)
+	}
+	d.Seen(5)
+	if lines = readDedupFile(t, path); len(lines) != 3 || lines[2] != "5" {
+		t.Fatalf("persisted %v after compact, want [3 4 5]", lines)
+	}
+}
+
+func TestNewTriggerDedupError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-exist", "dedup")
+	if _, err := NewTriggerDedup(2, path); err == nil {
+		t.Fatal("want error when the persistence directory does not exist")
+	}
+}
+
+func readDedupFile(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
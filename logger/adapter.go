@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ContextLogger 绑定任务ctx的日志，方法签名同zap的SugaredLogger及logrus的WithField，
+// 便于已有代码替换为任务日志，不依赖zap及logrus
+type ContextLogger struct {
+	ctx    context.Context
+	fields []interface{}
+}
+
+// For 获取绑定ctx的日志
+func For(ctx context.Context) *ContextLogger {
+	return &ContextLogger{ctx: ctx}
+}
+
+// With 追加键值对，之后的每条日志都带上，同zap SugaredLogger.With
+func (l *ContextLogger) With(keysAndValues ...interface{}) *ContextLogger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+	return &ContextLogger{ctx: l.ctx, fields: fields}
+}
+
+// WithField 同logrus WithField
+func (l *ContextLogger) WithField(key string, value interface{}) *ContextLogger {
+	return l.With(key, value)
+}
+
+// WithFields 同logrus WithFields，键值对顺序不固定
+func (l *ContextLogger) WithFields(fields map[string]interface{}) *ContextLogger {
+	keysAndValues := make([]interface{}, 0, len(fields)*2)
+	for k, v := range fields {
+		keysAndValues = append(keysAndValues, k, v)
+	}
+	return l.With(keysAndValues...)
+}
+
+func (l *ContextLogger) log(level Level, msg string, keysAndValues []interface{}) {
+	if len(l.fields) > 0 {
+		keysAndValues = append(l.fields[:len(l.fields):len(l.fields)], keysAndValues...)
+	}
+	output(l.ctx, level, sprintw(msg, keysAndValues))
+}
+
+func (l *ContextLogger) Debug(args ...interface{}) {
+	l.log(DebugLevel, sprint(args), nil)
+}
+
+func (l *ContextLogger) Info(args ...interface{}) {
+	l.log(InfoLevel, sprint(args), nil)
+}
+
+func (l *ContextLogger) Warn(args ...interface{}) {
+	l.log(WarnLevel, sprint(args), nil)
+}
+
+func (l *ContextLogger) Error(args ...interface{}) {
+	l.log(ErrorLevel, sprint(args), nil)
+}
+
+func (l *ContextLogger) Debugf(format string, args ...interface{}) {
+	l.log(DebugLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *ContextLogger) Infof(format string, args ...interface{}) {
+	l.log(InfoLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *ContextLogger) Warnf(format string, args ...interface{}) {
+	l.log(WarnLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *ContextLogger) Errorf(format string, args ...interface{}) {
+	l.log(ErrorLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *ContextLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.log(DebugLevel, msg, keysAndValues)
+}
+
+func (l *ContextLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.log(InfoLevel, msg, keysAndValues)
+}
+
+func (l *ContextLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.log(WarnLevel, msg, keysAndValues)
+}
+
+func (l *ContextLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.log(ErrorLevel, msg, keysAndValues)
+}
+
+// lineWriter 按行写入任务日志的io.Writer
+type lineWriter struct {
+	sync.Mutex
+	ctx   context.Context
+	level Level
+	buf   []byte
+}
+
+// NewWriter 写入的内容按行写入任务日志，可作为zap（zapcore.AddSync）及logrus（SetOutput）的输出，
+// 不完整的行等待换行后写入，调用方需在任务结束前写完最后一行
+func NewWriter(ctx context.Context, level Level) io.Writer {
+	return &lineWriter{ctx: ctx, level: level}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimRight(w.buf[:i], "\r")
+		output(w.ctx, w.level, string(line))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
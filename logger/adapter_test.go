@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+// jobLogMessages 任务日志每行的级别及内容，如 "INFO  hello"
+func jobLogMessages(s *MemoryStore, logId int64) []string {
+	var msgs []string
+	for _, line := range strings.Split(string(s.Bytes(logId)), "\r\n") {
+		if line == "" {
+			continue
+		}
+		// 去掉时间及任务信息前缀 "... [jobName#jobFunc]-[jobId:1]-["
+		if i := strings.Index(line, "]-[jobId:"); i >= 0 {
+			line = line[i+len("]-[jobId:"):]
+			line = line[strings.Index(line, "]-[")+len("]-["):]
+		}
+		msgs = append(msgs, strings.Replace(line, "]  ", "  ", 1))
+	}
+	return msgs
+}
+
+func useMemoryStore(t *testing.T) *MemoryStore {
+	s := NewMemoryStore()
+	useStore(t, s)
+	oldLevel := getLevel()
+	SetLevel(InfoLevel)
+	t.Cleanup(func() { SetLevel(oldLevel) })
+	return s
+}
+
+func TestNewWriter(t *testing.T) {
+	s := useMemoryStore(t)
+	w := NewWriter(benchLogContext(4501), WarnLevel)
+
+	w.Write([]byte("first\nsec"))
+	if got := jobLogMessages(s, 4501); len(got) != 1 || got[0] != "WARN  first" {
+		t.Fatalf("job log %q, want only the complete line", got)
+	}
+	w.Write([]byte("ond\r\nthird\n"))
+	want := []string{"WARN  first", "WARN  second", "WARN  third"}
+	if got := jobLogMessages(s, 4501); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("job log %q, want %q", got, want)
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	s := useMemoryStore(t)
+	l := For(benchLogContext(4502)).With("job", "sync").WithField("rows", 10)
+	l.Infow("done", "cost", "1s")
+	l.Debugf("hidden %d", 1)
+	l.Errorf("failed %d", 2)
+
+	want := []string{"INFO  done job=sync rows=10 cost=1s", "ERROR  failed 2 job=sync rows=10"}
+	if got := jobLogMessages(s, 4502); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("job log %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,221 @@
+package logger
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gongshen/xxl-job-client/constants"
+)
+
+// Cleaner 定时清理过期的任务日志及GLUE脚本，正在执行及等待执行的任务日志不会被删除。
+// FileStore开启压缩时同时压缩执行结束的日志
+type Cleaner struct {
+	//日志保留天数，按调度日期计算
+	RetentionDays int
+
+	//日志最大总字节数，超过时从最早的日志开始删除，小于等于0时不限制
+	MaxTotalSize int64
+
+	//GLUE脚本目录，为空时不清理GLUE脚本
+	GlueSourcePath string
+
+	//正在执行及等待执行的调度LogId
+	ActiveLogIds func() map[int64]struct{}
+
+	//正在使用的GLUE脚本文件名前缀
+	ActiveGlueSources func() map[string]struct{}
+
+	stopOnce  sync.Once
+	closeOnce sync.Once
+	stop      chan struct{} //Stop时关闭，停止定时清理
+}
+
+// AutoClean 定时清理，直到调用Stop
+func (c *Cleaner) AutoClean(interval time.Duration) {
+	c.Clean()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	stop := c.stopChan()
+	for {
+		select {
+		case <-t.C:
+			c.Clean()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop 停止定时清理，正在进行的清理完成后AutoClean返回
+func (c *Cleaner) Stop() {
+	c.closeOnce.Do(func() {
+		close(c.stopChan())
+	})
+}
+
+func (c *Cleaner) stopChan() chan struct{} {
+	c.stopOnce.Do(func() {
+		c.stop = make(chan struct{})
+	})
+	return c.stop
+}
+
+// Clean 清理一次，通过存储的List及Delete清理日志
+func (c *Cleaner) Clean() {
+	if c.GlueSourcePath != "" && c.RetentionDays > 0 {
+		c.cleanGlueSource()
+	}
+	if c.RetentionDays <= 0 && c.MaxTotalSize <= 0 {
+		c.compress()
+		return
+	}
+
+	st := GetStore()
+	logs, err := st.List(time.Now())
+	if err != nil {
+		log.Printf("list job logs failed. err:%v\n", err)
+		return
+	}
+	active := c.activeLogIds()
+	var removed []int64
+	remove := func(info LogInfo) bool {
+		if _, running := active[info.LogId]; running {
+			return false
+		}
+		if err := st.Delete(info.LogDateTime, info.LogId); err != nil {
+			log.Printf("remove job log failed. logId:%d,err:%v\n", info.LogId, err)
+			return false
+		}
+		removed = append(removed, info.LogId)
+		return true
+	}
+
+	expireTime := today().AddDate(0, 0, -c.RetentionDays).UnixMilli()
+	var total int64
+	remain := logs[:0]
+	for _, info := range logs {
+		if c.RetentionDays > 0 && info.LogDateTime < expireTime && remove(info) {
+			continue
+		}
+		total += info.Size
+		remain = append(remain, info)
+	}
+
+	// 超过最大总大小时从最早的日志开始删除，不删除当天的日志
+	todayTime := today().UnixMilli()
+	for _, info := range remain {
+		if c.MaxTotalSize <= 0 || total <= c.MaxTotalSize || info.LogDateTime >= todayTime {
+			break
+		}
+		if remove(info) {
+			total -= info.Size
+		}
+	}
+
+	if len(removed) > 0 {
+		log.Printf("job log cleaned. removed:%d,logIds:%v\n", len(removed), removed)
+	}
+	c.compress()
+}
+
+func (c *Cleaner) activeLogIds() map[int64]struct{} {
+	if c.ActiveLogIds == nil {
+		return map[int64]struct{}{}
+	}
+	return c.ActiveLogIds()
+}
+
+// compress 压缩执行结束超过FileStore.CompressAfter的日志。
+// CompressAfter小于等于0时压缩所有执行结束的日志，包括压缩后仍有写入的日志
+func (c *Cleaner) compress() {
+	fs, ok := GetStore().(*FileStore)
+	if !ok || !fs.Compress {
+		return
+	}
+	before := time.Now()
+	if fs.CompressAfter > 0 {
+		before = before.Add(-fs.CompressAfter)
+	}
+	active := c.activeLogIds()
+	for _, dir := range logDirs() {
+		compressDir(dir.path, before, active)
+	}
+}
+
+type logDir struct {
+	path string
+	date time.Time
+}
+
+// logDirs 按日期升序返回所有日期目录
+func logDirs() []logDir {
+	entries, err := os.ReadDir(GetBasePath())
+	if err != nil {
+		return nil
+	}
+	var dirs []logDir
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		date, err := time.ParseInLocation(constants.DateFormat, entry.Name(), location)
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, logDir{path: filepath.Join(GetBasePath(), entry.Name()), date: date})
+	}
+	sort.Slice(dirs, func(i, k int) bool {
+		return dirs[i].date.Before(dirs[k].date)
+	})
+	return dirs
+}
+
+// cleanGlueSource 删除超过保留天数且没有任务线程使用的GLUE脚本
+func (c *Cleaner) cleanGlueSource() {
+	entries, err := os.ReadDir(c.GlueSourcePath)
+	if err != nil {
+		return
+	}
+	active := map[string]struct{}{}
+	if c.ActiveGlueSources != nil {
+		active = c.ActiveGlueSources()
+	}
+	expireTime := time.Now().AddDate(0, 0, -c.RetentionDays)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || info.ModTime().After(expireTime) {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, ok := active[name]; ok {
+			continue
+		}
+		path := filepath.Join(c.GlueSourcePath, entry.Name())
+		if err = os.Remove(path); err != nil {
+			log.Printf("remove glue source failed. path:%s,err:%v\n", path, err)
+			continue
+		}
+		log.Printf("glue source cleaned. path:%s\n", path)
+	}
+}
+
+// parseLogId 从日志文件名 {logId}.log 中解析LogId
+func parseLogId(name string) (int64, bool) {
+	idx := strings.IndexByte(name, '.')
+	if idx <= 0 {
+		return 0, false
+	}
+	logId, err := strconv.ParseInt(name[:idx], 10, 64)
+	return logId, err == nil
+}
+
+func today() time.Time {
+	y, m, d := time.Now().In(location).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, location)
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useStore(t *testing.T, s LogStore) {
+	oldPath, oldStore := GetBasePath(), GetStore()
+	SetBasePath(t.TempDir())
+	SetStore(s)
+	t.Cleanup(func() {
+		SetBasePath(oldPath)
+		SetStore(oldStore)
+	})
+}
+
+func daysAgo(days int) int64 {
+	return today().AddDate(0, 0, -days).Add(time.Hour).UnixMilli()
+}
+
+func TestCleanerRetention(t *testing.T) {
+	for _, s := range []LogStore{NewMemoryStore(), &FileStore{}} {
+		useStore(t, s)
+		line := []byte(strings.Repeat("x", 99) + "\n")
+		s.Append(daysAgo(10), 1, line)
+		s.Append(daysAgo(10), 2, line)
+		s.Append(daysAgo(1), 3, line)
+		s.Append(daysAgo(0), 4, line)
+
+		c := &Cleaner{
+			RetentionDays: 3,
+			ActiveLogIds:  func() map[int64]struct{} { return map[int64]struct{}{2: {}} },
+		}
+		c.Clean()
+		logs, err := s.List(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, info := range logs {
+			ids = append(ids, info.LogId)
+		}
+		if len(ids) != 3 || ids[0] != 2 || ids[1] != 3 || ids[2] != 4 {
+			t.Fatalf("%T remain logs %v, want [2 3 4]", s, ids)
+		}
+
+		// 超过总大小时从最早的日志开始删除，正在执行及当天的日志保留
+		c = &Cleaner{MaxTotalSize: 150}
+		c.Clean()
+		logs, _ = s.List(time.Now())
+		if len(logs) != 1 || logs[0].LogId != 4 {
+			t.Fatalf("%T remain logs %v, want only logId 4", s, logs)
+		}
+	}
+}
+
+func TestFileStoreDeleteRemovesEmptyDir(t *testing.T) {
+	s := &FileStore{}
+	useStore(t, s)
+	logDateTime := daysAgo(5)
+	s.Append(logDateTime, 1, []byte("a\n"))
+	if err := s.Delete(logDateTime, 1); err != nil {
+		t.Fatal(err)
+	}
+	dir := GetLogPath(time.UnixMilli(logDateTime))
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("empty date dir not removed: %v", err)
+	}
+}
+
+func TestAutoCleanStop(t *testing.T) {
+	useStore(t, NewMemoryStore())
+	c := &Cleaner{RetentionDays: 1}
+	done := make(chan struct{})
+	go func() {
+		c.AutoClean(time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	c.Stop()
+	c.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("AutoClean not stopped")
+	}
+}
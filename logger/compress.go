@@ -0,0 +1,271 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gzipSuffix     = ".gz"
+	gzipMemberSize = 256 * 1024 //每个gzip分段压缩前的最大字节数，读取时从最近的分段开始解压
+)
+
+// 日志文件锁，按文件名分段，写入与压缩互斥，避免压缩过程中追加的日志丢失
+var fileLocks [64]sync.Mutex
+
+func fileLock(fileName string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(fileName))
+	return &fileLocks[h.Sum32()%uint32(len(fileLocks))]
+}
+
+// compressLog 将日志文件压缩为 {logId}.log.gz 并删除原文件。
+// 已存在压缩文件时（执行结束后仍有写入）作为新的gzip分段追加到压缩文件，读取时按顺序解压。
+// 压缩期间持有文件锁，同一日志的写入等待压缩完成后写入新的 {logId}.log
+func compressLog(fileName string) error {
+	mu := fileLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+
+	src, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer src.Close()
+
+	gzName := fileName + gzipSuffix
+	tmpName := gzName + ".tmp"
+	dst, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err = writeGzip(dst, gzName, src); err != nil {
+		dst.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err = os.Rename(tmpName, gzName); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	// 压缩后重新建立索引，避免索引与新的 {logId}.log 不一致
+	logIndexes.remove(fileName)
+	return os.Remove(fileName)
+}
+
+// writeGzip 先复制已存在的压缩文件，再将src按gzipMemberSize分段压缩后追加
+func writeGzip(dst io.Writer, gzName string, src io.Reader) error {
+	if prev, err := os.Open(gzName); err == nil {
+		_, err = io.Copy(dst, prev)
+		prev.Close()
+		if err != nil {
+			return err
+		}
+	}
+	zw := gzip.NewWriter(dst)
+	for {
+		n, err := io.CopyN(zw, src, gzipMemberSize)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err = zw.Close(); err != nil {
+			return err
+		}
+		if n < gzipMemberSize {
+			return nil
+		}
+		zw.Reset(dst)
+	}
+}
+
+// logReader 日志内容，压缩的日志解压后与之后写入的 {logId}.log 按顺序读取
+type logReader struct {
+	io.Reader
+	gz    *gzipReader
+	plain *os.File
+}
+
+// openLog 打开日志文件，依次读取 {logId}.log.gz 及 {logId}.log，都不存在时返回错误。
+// index 记录读取压缩日志时经过的gzip分段，skip时从最近的分段开始解压
+func openLog(fileName string, index *lineIndex) (*logReader, error) {
+	// 持有文件锁打开，避免压缩完成前后打开的文件内容重复或缺失
+	mu := fileLock(fileName)
+	mu.Lock()
+	gz, gzErr := os.Open(fileName + gzipSuffix)
+	plain, err := os.Open(fileName)
+	mu.Unlock()
+
+	if err != nil && !os.IsNotExist(err) {
+		closeFiles(gz)
+		return nil, err
+	}
+	if gzErr != nil && !os.IsNotExist(gzErr) {
+		closeFiles(plain)
+		return nil, gzErr
+	}
+	if gz == nil && plain == nil {
+		return nil, err
+	}
+	r := &logReader{plain: plain}
+	if gz == nil {
+		r.Reader = plain
+		return r, nil
+	}
+	r.gz = &gzipReader{file: gz, index: index}
+	if err = r.gz.reset(gzipMember{}); err != nil {
+		closeFiles(gz, plain)
+		return nil, err
+	}
+	if plain == nil {
+		r.Reader = r.gz
+	} else {
+		r.Reader = io.MultiReader(r.gz, plain)
+	}
+	return r, nil
+}
+
+// skip 跳过offset字节，只有未压缩的日志时直接定位，否则从offset之前最近的gzip分段开始解压
+func (r *logReader) skip(offset int64) error {
+	if r.gz == nil {
+		_, err := r.plain.Seek(offset, io.SeekStart)
+		return err
+	}
+	m := r.gz.index.member(offset)
+	if m.pos > 0 {
+		if err := r.gz.reset(m); err != nil {
+			return err
+		}
+	}
+	_, err := io.CopyN(io.Discard, r.Reader, offset-m.raw)
+	return err
+}
+
+func (r *logReader) Close() error {
+	if r.gz != nil {
+		r.gz.zr.Close()
+		r.gz.file.Close()
+	}
+	closeFiles(r.plain)
+	return nil
+}
+
+// gzipReader 逐个gzip分段解压，并将经过的分段位置记录到索引
+type gzipReader struct {
+	file  *os.File
+	cr    *countReader
+	zr    *gzip.Reader
+	index *lineIndex
+	raw   int64 //已解压的字节数
+}
+
+// reset 从分段m开始解压
+func (g *gzipReader) reset(m gzipMember) error {
+	if _, err := g.file.Seek(m.pos, io.SeekStart); err != nil {
+		return err
+	}
+	g.cr = &countReader{r: bufio.NewReader(g.file), n: m.pos}
+	zr, err := gzip.NewReader(g.cr)
+	if err != nil {
+		return err
+	}
+	zr.Multistream(false)
+	g.zr, g.raw = zr, m.raw
+	return nil
+}
+
+func (g *gzipReader) Read(p []byte) (int, error) {
+	for {
+		n, err := g.zr.Read(p)
+		g.raw += int64(n)
+		if err != io.EOF {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+		// 当前分段结束，countReader实现了io.ByteReader，解压时不会多读，计数即为下一分段的位置
+		pos := g.cr.n
+		if err = g.zr.Reset(g.cr); err != nil {
+			return 0, err
+		}
+		g.zr.Multistream(false)
+		g.index.addMember(gzipMember{raw: g.raw, pos: pos})
+	}
+}
+
+// countReader 记录已读取的压缩文件字节数
+type countReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+func closeFiles(files ...*os.File) {
+	for _, f := range files {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+// compressDir 压缩日期目录下修改时间早于before且不在执行中的日志
+func compressDir(dir string, before time.Time, active map[int64]struct{}) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var compressed int
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		logId, ok := parseLogId(entry.Name())
+		if !ok {
+			continue
+		}
+		if _, running := active[logId]; running {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(before) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err = compressLog(path); err != nil {
+			log.Printf("compress job log failed. path:%s,err:%v\n", path, err)
+			continue
+		}
+		compressed++
+	}
+	if compressed > 0 {
+		log.Printf("job log compressed. dir:%s,compressed:%d\n", dir, compressed)
+	}
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func appendLines(t *testing.T, s LogStore, logDateTime, logId int64, from, to int) {
+	t.Helper()
+	for i := from; i <= to; i++ {
+		if err := s.Append(logDateTime, logId, []byte(fmt.Sprintf("line %d\n", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkLines(t *testing.T, s LogStore, logDateTime, logId int64, fromLineNum int32, want int) {
+	t.Helper()
+	toLineNum, content, _ := s.Read(logDateTime, logId, fromLineNum, 0, 0)
+	if int(toLineNum) != want {
+		t.Fatalf("read from %d toLineNum %d, want %d", fromLineNum, toLineNum, want)
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if len(lines) != want-int(fromLineNum)+1 {
+		t.Fatalf("read from %d got %d lines, want %d", fromLineNum, len(lines), want-int(fromLineNum)+1)
+	}
+	for i, line := range lines {
+		if expect := fmt.Sprintf("line %d", int(fromLineNum)+i); line != expect {
+			t.Fatalf("line %d is %q, want %q", int(fromLineNum)+i, line, expect)
+		}
+	}
+}
+
+func TestCompressLateWrite(t *testing.T) {
+	s := &FileStore{Compress: true}
+	useStore(t, s)
+	logDateTime := time.Now().UnixMilli()
+	fileName := GetLogFile(logDateTime, 1)
+
+	appendLines(t, s, logDateTime, 1, 1, 300)
+	checkLines(t, s, logDateTime, 1, 260, 300)
+	if err := compressLog(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	// 压缩后的写入与压缩的内容按顺序读取
+	appendLines(t, s, logDateTime, 1, 301, 301)
+	checkLines(t, s, logDateTime, 1, 1, 301)
+	checkLines(t, s, logDateTime, 1, 260, 301)
+
+	// Cleaner再次压缩执行结束后写入的日志
+	(&Cleaner{}).Clean()
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Fatalf("late log not compressed. err:%v", err)
+	}
+	checkLines(t, s, logDateTime, 1, 1, 301)
+	checkLines(t, s, logDateTime, 1, 260, 301)
+}
+
+func TestCompressConcurrentAppend(t *testing.T) {
+	s := &FileStore{Compress: true}
+	useStore(t, s)
+	logDateTime := time.Now().UnixMilli()
+	fileName := GetLogFile(logDateTime, 1)
+
+	const total = 1000
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := compressLog(fileName); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	appendLines(t, s, logDateTime, 1, 1, total)
+	close(done)
+	wg.Wait()
+
+	checkLines(t, s, logDateTime, 1, 1, total)
+}
+
+func TestCompressReadMembers(t *testing.T) {
+	s := &FileStore{Compress: true}
+	useStore(t, s)
+	logDateTime := time.Now().UnixMilli()
+	fileName := GetLogFile(logDateTime, 1)
+
+	// 一次写入，压缩为多个gzip分段
+	const total = 100000
+	var buf strings.Builder
+	for i := 1; i <= total; i++ {
+		fmt.Fprintf(&buf, "line %d\n", i)
+	}
+	if err := s.Append(logDateTime, 1, []byte(buf.String())); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressLog(fileName); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, s, logDateTime, 1, total+1, total+1)
+
+	// 分页读取，经过的分段记录到索引，之后的分页从最近的分段开始解压
+	var lineNum int32
+	for {
+		toLineNum, content, eof := s.Read(logDateTime, 1, lineNum+1, 5000, 0)
+		lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		for i, line := range lines {
+			if expect := fmt.Sprintf("line %d", int(lineNum)+1+i); line != expect {
+				t.Fatalf("line %d is %q, want %q", int(lineNum)+1+i, line, expect)
+			}
+		}
+		lineNum = toLineNum
+		if eof {
+			break
+		}
+	}
+	if lineNum != total+1 {
+		t.Fatalf("paged to line %d, want %d", lineNum, total+1)
+	}
+	index := logIndexes.get(fileName)
+	if want := buf.Len() / gzipMemberSize; len(index.members) != want {
+		t.Fatalf("recorded %d gzip members, want %d", len(index.members), want)
+	}
+	checkLines(t, s, logDateTime, 1, total-10, total+1)
+}
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Level 任务日志级别，低于最低级别的日志不写入
+type Level int32
+
+const (
+	DebugLevel Level = iota - 1
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "DEBUG"
+	case InfoLevel:
+		return "INFO"
+	case WarnLevel:
+		return "WARN"
+	case ErrorLevel:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int32(l))
+}
+
+var minLevel = int32(InfoLevel)
+
+// SetLevel 设置任务日志最低级别，默认InfoLevel
+func SetLevel(level Level) {
+	atomic.StoreInt32(&minLevel, int32(level))
+}
+
+func getLevel() Level {
+	return Level(atomic.LoadInt32(&minLevel))
+}
+
+type runStatsKey struct{}
+
+type runStats struct {
+	errors int32
+}
+
+// WithRunStats 本次执行统计ERROR级别日志数量，通过ErrorCount获取
+func WithRunStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, runStatsKey{}, &runStats{})
+}
+
+// ErrorCount 本次执行写入的ERROR级别日志数量
+func ErrorCount(ctx context.Context) int {
+	stats, ok := ctx.Value(runStatsKey{}).(*runStats)
+	if !ok {
+		return 0
+	}
+	return int(atomic.LoadInt32(&stats.errors))
+}
+
+func countError(ctx context.Context) {
+	if stats, ok := ctx.Value(runStatsKey{}).(*runStats); ok {
+		atomic.AddInt32(&stats.errors, 1)
+	}
+}
+
+func sprint(args []interface{}) string {
+	var sb strings.Builder
+	for _, arg := range args {
+		sb.WriteString(fmt.Sprintf("%v", arg))
+	}
+	return sb.String()
+}
+
+// sprintw 日志信息后追加 key=value
+func sprintw(msg string, keysAndValues []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		sb.WriteString(" ")
+		sb.WriteString(fmt.Sprintf("%v", keysAndValues[i]))
+		sb.WriteString("=")
+		if i+1 < len(keysAndValues) {
+			sb.WriteString(fmt.Sprintf("%v", keysAndValues[i+1]))
+		}
+	}
+	return sb.String()
+}
+
+func Debug(ctx context.Context, args ...interface{}) {
+	output(ctx, DebugLevel, sprint(args))
+}
+
+func Info(ctx context.Context, args ...interface{}) {
+	output(ctx, InfoLevel, sprint(args))
+}
+
+func Warn(ctx context.Context, args ...interface{}) {
+	output(ctx, WarnLevel, sprint(args))
+}
+
+func Error(ctx context.Context, args ...interface{}) {
+	output(ctx, ErrorLevel, sprint(args))
+}
+
+func Debugf(ctx context.Context, format string, args ...interface{}) {
+	output(ctx, DebugLevel, fmt.Sprintf(format, args...))
+}
+
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	output(ctx, InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	output(ctx, WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func Errorf(ctx context.Context, format string, args ...interface{}) {
+	output(ctx, ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+// Debugw 日志信息后追加键值对，如 Debugw(ctx, "sync done", "rows", 10)
+func Debugw(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	output(ctx, DebugLevel, sprintw(msg, keysAndValues))
+}
+
+func Infow(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	output(ctx, InfoLevel, sprintw(msg, keysAndValues))
+}
+
+func Warnw(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	output(ctx, WarnLevel, sprintw(msg, keysAndValues))
+}
+
+func Errorw(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	output(ctx, ErrorLevel, sprintw(msg, keysAndValues))
+}
@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	indexInterval = 256  //每隔多少行记录一次偏移量
+	maxIndexFiles = 1024 //最多缓存多少个日志文件的索引
+)
+
+// lineIndex 日志文件的稀疏行索引，记录每indexInterval行结束时的文件偏移量
+type lineIndex struct {
+	sync.Mutex
+	offsets []int64      //offsets[i] 为第(i+1)*indexInterval行结束时的偏移量
+	members []gzipMember //压缩日志中已读到的gzip分段位置，按解压后的偏移量递增
+}
+
+// gzipMember gzip分段开始时解压后的偏移量及在压缩文件中的偏移量
+type gzipMember struct {
+	raw int64
+	pos int64
+}
+
+// seek 返回小于fromLineNum的最近的已索引行号及该行结束时的偏移量
+func (x *lineIndex) seek(fromLineNum int32) (lineNum int32, offset int64) {
+	x.Lock()
+	defer x.Unlock()
+	i := int(fromLineNum-1)/indexInterval - 1
+	if i >= len(x.offsets) {
+		i = len(x.offsets) - 1
+	}
+	if i < 0 {
+		return 0, 0
+	}
+	return int32((i + 1) * indexInterval), x.offsets[i]
+}
+
+// add 记录lineNum行结束时的偏移量
+func (x *lineIndex) add(lineNum int32, offset int64) {
+	if lineNum%indexInterval != 0 {
+		return
+	}
+	x.Lock()
+	defer x.Unlock()
+	if int(lineNum/indexInterval) == len(x.offsets)+1 {
+		x.offsets = append(x.offsets, offset)
+	}
+}
+
+// member 返回解压后的偏移量不超过offset的最近的gzip分段，没有时从压缩文件开头读取
+func (x *lineIndex) member(offset int64) gzipMember {
+	x.Lock()
+	defer x.Unlock()
+	i := sort.Search(len(x.members), func(i int) bool { return x.members[i].raw > offset })
+	if i == 0 {
+		return gzipMember{}
+	}
+	return x.members[i-1]
+}
+
+// addMember 记录读取时经过的gzip分段
+func (x *lineIndex) addMember(m gzipMember) {
+	x.Lock()
+	defer x.Unlock()
+	if n := len(x.members); n == 0 || m.raw > x.members[n-1].raw {
+		x.members = append(x.members, m)
+	}
+}
+
+type indexCache struct {
+	sync.Mutex
+	indexes map[string]*lineIndex
+	order   []string
+}
+
+var logIndexes = &indexCache{indexes: make(map[string]*lineIndex)}
+
+// get 获取日志文件的索引，超过maxIndexFiles时淘汰最早创建的索引
+func (c *indexCache) get(fileName string) *lineIndex {
+	c.Lock()
+	defer c.Unlock()
+	if index, ok := c.indexes[fileName]; ok {
+		return index
+	}
+	if len(c.order) >= maxIndexFiles {
+		delete(c.indexes, c.order[0])
+		c.order = c.order[1:]
+	}
+	index := &lineIndex{}
+	c.indexes[fileName] = index
+	c.order = append(c.order, fileName)
+	return index
+}
+
+// remove 日志文件被删除或改写时移除索引
+func (c *indexCache) remove(fileName string) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.indexes[fileName]; !ok {
+		return
+	}
+	delete(c.indexes, fileName)
+	for i, name := range c.order {
+		if name == fileName {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package logger
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIndexCacheRemove(t *testing.T) {
+	c := &indexCache{indexes: make(map[string]*lineIndex)}
+	live := c.get("a")
+	c.remove("a")
+	c.remove("a")
+	live = c.get("a")
+	if len(c.order) != 1 {
+		t.Fatalf("order has %d names, want 1", len(c.order))
+	}
+	for i := 0; i < maxIndexFiles-1; i++ {
+		c.get(fmt.Sprintf("f%d", i))
+	}
+	if c.get("a") != live {
+		t.Fatal("live index evicted before the cache is full")
+	}
+}
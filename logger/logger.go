@@ -1,15 +1,16 @@
 package logger
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/gongshen/xxl-job-client/constants"
-	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,64 +21,151 @@ type LogResult struct {
 	IsEnd       bool   `json:"isEnd"`
 }
 
-func Info(ctx context.Context, args ...interface{}) {
-	jobMap := ctx.Value("jobParam")
-	if jobMap != nil {
-		jobParamMap, ok := jobMap.(map[string]map[string]interface{})["logParam"]
-		if ok {
-			logid, ok := jobParamMap["logId"]
-			if ok {
-				nowTime := time.Now()
-
-				var buffer bytes.Buffer
-				buffer.WriteString(nowTime.Format(constants.DateTimeFormat))
-				buffer.WriteString("  [")
-
-				jobName, ok := jobParamMap["jobName"]
-				if ok {
-					buffer.WriteString(jobName.(string))
-				}
-				buffer.WriteString("#")
-				jobFunc, ok := jobParamMap["jobFunc"]
-				if ok {
-					buffer.WriteString(jobFunc.(string))
-				}
-				buffer.WriteString("]-[")
+// jobLogParam 获取ctx中的任务日志参数，不在任务中执行时返回false
+func jobLogParam(ctx context.Context) (map[string]interface{}, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	jobMap, ok := ctx.Value("jobParam").(map[string]map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	jobParamMap, ok := jobMap["logParam"]
+	if !ok {
+		return nil, false
+	}
+	if _, ok = jobParamMap["logId"]; !ok {
+		return nil, false
+	}
+	return jobParamMap, true
+}
 
-				jobId, ok := jobParamMap["jobId"]
-				if ok {
-					buffer.WriteString(fmt.Sprintf("jobId:%d", jobId.(int32)))
-				}
-				buffer.WriteString("]  ")
-				if len(args) > 0 {
-					for _, arg := range args {
-						buffer.WriteString(fmt.Sprintf("%v", arg))
-					}
-				}
-				buffer.WriteString("\r\n")
+// InJob ctx是否为任务执行的上下文，是则日志写入任务日志
+func InJob(ctx context.Context) bool {
+	_, ok := jobLogParam(ctx)
+	return ok
+}
 
-				logId := logid.(int64)
-				writeLog(GetLogPath(nowTime), fmt.Sprintf("%d", logId)+".log", buffer.String())
-			}
-		}
+var linePool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// output 按xxl日志格式写入任务日志，ctx中没有任务信息时忽略
+func output(ctx context.Context, level Level, msg string) {
+	if level < getLevel() {
+		return
+	}
+	jobParamMap, ok := jobLogParam(ctx)
+	if !ok {
+		return
+	}
+
+	bp := linePool.Get().(*[]byte)
+	defer linePool.Put(bp)
+	buffer := time.Now().AppendFormat((*bp)[:0], constants.DateTimeFormat)
+	buffer = append(buffer, "  ["...)
+	if jobName, ok := jobParamMap["jobName"].(string); ok {
+		buffer = append(buffer, jobName...)
+	}
+	buffer = append(buffer, '#')
+	if jobFunc, ok := jobParamMap["jobFunc"].(string); ok {
+		buffer = append(buffer, jobFunc...)
+	}
+	buffer = append(buffer, "]-["...)
+	if jobId, ok := jobParamMap["jobId"].(int32); ok {
+		buffer = append(buffer, "jobId:"...)
+		buffer = strconv.AppendInt(buffer, int64(jobId), 10)
+	}
+	buffer = append(buffer, "]-["...)
+	buffer = append(buffer, level.String()...)
+	buffer = append(buffer, "]  "...)
+	buffer = append(buffer, msg...)
+	buffer = append(buffer, "\r\n"...)
+	if cap(buffer) <= 64*1024 {
+		// 超长的日志不放回池中，避免常驻内存
+		*bp = buffer
+	}
+
+	if level >= ErrorLevel {
+		countError(ctx)
+	}
+	logId := jobParamMap["logId"].(int64)
+	if r := CurrentRun(logId); r != nil {
+		r.Write(buffer)
+		return
+	}
+	if fn := getMirror(); fn != nil {
+		jobId, _ := jobParamMap["jobId"].(int32)
+		jobName, _ := jobParamMap["jobName"].(string)
+		fn(jobId, logId, jobName, string(bytes.TrimRight(buffer, "\r\n")))
+	}
+	// 同一次调度的日志写入调度时间的日期目录，跨天执行也写入同一个文件
+	logDateTime, _ := jobParamMap["logDateTime"].(int64)
+	GetStore().Append(logDateTime, logId, buffer)
+}
+
+// 任务日志根目录，按日期分目录存放
+var basePath = constants.BasePath
+
+// SetBasePath 设置任务日志根目录，需在执行器启动前设置
+func SetBasePath(path string) {
+	basePath = path
+}
+
+func GetBasePath() string {
+	return basePath
+}
+
+// 日期目录的时区
+var location = time.Local
+
+// SetLocation 设置日期目录的时区，需在执行器启动前设置
+func SetLocation(loc *time.Location) {
+	if loc != nil {
+		location = loc
 	}
 }
 
 func GetLogPath(nowTime time.Time) string {
-	return constants.BasePath + nowTime.Format(constants.DateFormat)
+	return filepath.Join(basePath, nowTime.In(location).Format(constants.DateFormat))
 }
 
+// GetLogFile 调度的日志文件，logDateTime为调度时间（毫秒），小于等于0时使用当前时间
+func GetLogFile(logDateTime, logId int64) string {
+	logTime := time.Now()
+	if logDateTime > 0 {
+		logTime = time.UnixMilli(logDateTime)
+	}
+	return filepath.Join(GetLogPath(logTime), strconv.FormatInt(logId, 10)+".log")
+}
+
+// InitLogPath 创建任务日志根目录并检查写权限，非文件存储时不检查
 func InitLogPath() error {
-	_, err := os.Stat(GetLogPath(time.Now()))
-	if err != nil && os.IsNotExist(err) {
-		err = os.MkdirAll(constants.BasePath, os.ModePerm)
+	if _, ok := GetStore().(*FileStore); !ok {
+		return nil
+	}
+	return CheckDir(basePath)
+}
+
+// CheckDir 目录不存在时创建，并检查是否有写权限
+func CheckDir(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("create dir %s failed: %w", dir, err)
+	}
+	file, err := os.CreateTemp(dir, ".write_check_")
+	if err != nil {
+		return fmt.Errorf("dir %s is not writable: %w", dir, err)
 	}
-	return err
+	file.Close()
+	return os.Remove(file.Name())
 }
 
 func writeLog(logPath, logFile, log string) error {
 	if strings.Trim(logFile, " ") != "" {
-		fileFullPath := logPath + "/" + logFile
+		fileFullPath := filepath.Join(logPath, logFile)
 		file, err := os.OpenFile(fileFullPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 		if err != nil && os.IsNotExist(err) {
 			err = os.MkdirAll(logPath, os.ModePerm)
@@ -103,27 +191,15 @@ func writeLog(logPath, logFile, log string) error {
 	return nil
 }
 
+// ReadLog 读取fromLineNum开始的所有日志，返回最后一行的行号
 func ReadLog(logDateTim, logId int64, fromLineNum int32) (line int32, content string) {
-	nowtime := time.Unix(logDateTim/1000, 0)
-	fileName := GetLogPath(nowtime) + "/" + fmt.Sprintf("%d", logId) + ".log"
-	file, err := os.Open(fileName)
-	totalLines := int32(1)
-	var buffer bytes.Buffer
-	if err == nil {
-		defer file.Close()
-
-		rd := bufio.NewReader(file)
-		for {
-
-			line, err := rd.ReadString('\n')
-			if err != nil || io.EOF == err {
-				break
-			}
-			if totalLines >= fromLineNum {
-				buffer.WriteString(line)
-			}
-			totalLines++
-		}
-	}
-	return totalLines, buffer.String()
+	line, content, _ = ReadLogPage(logDateTim, logId, fromLineNum, 0, 0)
+	return line, content
+}
+
+// ReadLogPage 读取fromLineNum开始的日志，行号从1开始，最多返回maxLines行、maxBytes字节（小于等于0时不限制）。
+// 返回最后一行的行号，没有读取到日志时为fromLineNum-1；eof为true时已读到文件末尾。
+// 只返回完整的行，正在写入的最后一行下次读取
+func ReadLogPage(logDateTim, logId int64, fromLineNum int32, maxLines, maxBytes int) (toLineNum int32, content string, eof bool) {
+	return GetStore().Read(logDateTim, logId, fromLineNum, maxLines, maxBytes)
 }
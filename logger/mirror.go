@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// MirrorFunc 任务日志镜像输出，每行调用一次，line不包含换行符。
+// 在写日志的goroutine中同步调用，需尽快返回
+type MirrorFunc func(jobId int32, logId int64, handler string, line string)
+
+type mirrorHolder struct {
+	fn MirrorFunc
+}
+
+var mirror atomic.Value
+
+func init() {
+	mirror.Store(mirrorHolder{})
+}
+
+// SetMirror 设置任务日志镜像输出，任务日志及GLUE脚本的输出同时按行输出到fn，为空时不输出
+func SetMirror(fn MirrorFunc) {
+	mirror.Store(mirrorHolder{fn})
+}
+
+func getMirror() MirrorFunc {
+	return mirror.Load().(mirrorHolder).fn
+}
+
+// MirrorTo 按行输出到w，如os.Stdout，每行以 jobId=1 logId=2 handler=demo 开头
+func MirrorTo(w io.Writer) MirrorFunc {
+	var mu sync.Mutex
+	return func(jobId int32, logId int64, handler string, line string) {
+		buf := make([]byte, 0, len(line)+64)
+		buf = append(buf, "jobId="...)
+		buf = strconv.AppendInt(buf, int64(jobId), 10)
+		buf = append(buf, " logId="...)
+		buf = strconv.AppendInt(buf, logId, 10)
+		buf = append(buf, " handler="...)
+		buf = append(buf, handler...)
+		buf = append(buf, ' ')
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+		mu.Lock()
+		w.Write(buf)
+		mu.Unlock()
+	}
+}
+
+// mirrorLines 按行输出p，不完整的行暂存在partial中，返回新的partial
+func mirrorLines(fn MirrorFunc, jobId int32, logId int64, handler string, partial, p []byte) []byte {
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			break
+		}
+		line := p[:i]
+		if len(partial) > 0 {
+			line = append(partial, line...)
+			partial = partial[:0]
+		}
+		fn(jobId, logId, handler, string(bytes.TrimRight(line, "\r")))
+		p = p[i+1:]
+	}
+	return append(partial, p...)
+}
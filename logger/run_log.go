@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gongshen/xxl-job-client/constants"
+)
+
+const runLogBufferSize = 32 * 1024
+
+// 缓存日志的刷盘间隔，小于等于0时不缓存，每条日志直接写入存储
+var flushInterval = int64(time.Second)
+
+// SetFlushInterval 设置任务日志刷盘间隔，默认1秒，小于等于0时不缓存
+func SetFlushInterval(d time.Duration) {
+	atomic.StoreInt64(&flushInterval, int64(d))
+}
+
+func getFlushInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&flushInterval))
+}
+
+// 每次调度最多写入的日志行数及字节数，小于等于0时不限制
+var (
+	runMaxLines int64
+	runMaxBytes int64
+)
+
+// SetRunLogLimit 设置每次调度最多写入的日志行数及字节数，超过后的日志丢弃，小于等于0时不限制
+func SetRunLogLimit(maxLines, maxBytes int64) {
+	atomic.StoreInt64(&runMaxLines, maxLines)
+	atomic.StoreInt64(&runMaxBytes, maxBytes)
+}
+
+// RunLog 一次调度的任务日志，执行期间写入先缓存，定时及执行结束时写入存储。
+// 可在任务启动的多个goroutine中同时写入
+type RunLog struct {
+	sync.Mutex
+	logDateTime int64
+	logId       int64
+	store       LogStore
+	w           *bufio.Writer //为空时不缓存
+	interval    time.Duration
+	timer       *time.Timer
+	closed      bool
+
+	maxLines int64
+	maxBytes int64
+	lines    int64
+	bytes    int64
+	dropped  int64 //超过限制丢弃的字节数
+
+	jobId   int32
+	handler string
+	mirror  MirrorFunc
+	partial []byte //镜像输出不完整的行
+}
+
+// 执行中的任务日志，key为logId
+var runLogs sync.Map
+
+// OpenRun 打开调度的任务日志，执行结束时需调用Close。同一日志已打开时返回nil
+func OpenRun(logDateTime, logId int64, jobId int32, handler string) *RunLog {
+	r := &RunLog{
+		logDateTime: logDateTime,
+		logId:       logId,
+		jobId:       jobId,
+		handler:     handler,
+		mirror:      getMirror(),
+		store:       GetStore(),
+		interval:    getFlushInterval(),
+		maxLines:    atomic.LoadInt64(&runMaxLines),
+		maxBytes:    atomic.LoadInt64(&runMaxBytes),
+	}
+	if r.interval > 0 {
+		r.w = bufio.NewWriterSize(storeWriter{r}, runLogBufferSize)
+	}
+	if _, loaded := runLogs.LoadOrStore(logId, r); loaded {
+		// 同一日志已打开，日志写入已打开的RunLog，由打开方关闭
+		return nil
+	}
+	return r
+}
+
+// CurrentRun 获取执行中的任务日志，没有时返回nil
+func CurrentRun(logId int64) *RunLog {
+	if r, ok := runLogs.Load(logId); ok {
+		return r.(*RunLog)
+	}
+	return nil
+}
+
+// storeWriter 缓存写满或刷盘时写入存储
+type storeWriter struct {
+	r *RunLog
+}
+
+func (w storeWriter) Write(p []byte) (int, error) {
+	if err := w.r.store.Append(w.r.logDateTime, w.r.logId, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Write 写入日志原始内容，如脚本的标准输出。超过限制的部分丢弃，第一次丢弃时写入截断标记
+func (r *RunLog) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	n := r.allow(p)
+	if n < len(p) {
+		if r.dropped == 0 {
+			defer r.write([]byte(r.marker(fmt.Sprintf("job log exceeds the limit (maxLines:%d,maxBytes:%d), further output is dropped", r.maxLines, r.maxBytes))))
+		}
+		r.dropped += int64(len(p) - n)
+	}
+	if n == 0 {
+		return len(p), nil
+	}
+	if _, err := r.write(p[:n]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// allow 按限制返回可写入的字节数，只写入完整的行
+func (r *RunLog) allow(p []byte) int {
+	if r.dropped > 0 {
+		// 已截断，之后的日志都丢弃，避免截断后日志不连续
+		return 0
+	}
+	if r.maxLines <= 0 && r.maxBytes <= 0 {
+		return len(p)
+	}
+	n := len(p)
+	if r.maxBytes > 0 && r.bytes+int64(n) > r.maxBytes {
+		n = int(r.maxBytes - r.bytes)
+		n = bytes.LastIndexByte(p[:n], '\n') + 1
+	}
+	if r.maxLines > 0 {
+		remain := r.maxLines - r.lines
+		for i := 0; i < n; i++ {
+			if p[i] == '\n' {
+				if remain == 0 {
+					n = i
+					break
+				}
+				remain--
+			}
+		}
+		if remain == 0 && n > 0 && p[n-1] != '\n' {
+			// 已达到行数限制，不写入不完整的行
+			n = bytes.LastIndexByte(p[:n], '\n') + 1
+		}
+		r.lines = r.maxLines - remain
+	}
+	r.bytes += int64(n)
+	return n
+}
+
+// marker 截断信息，不计入限制
+func (r *RunLog) marker(msg string) string {
+	return time.Now().Format(constants.DateTimeFormat) + "  [TRUNCATED]  " + msg + "\r\n"
+}
+
+// Dropped 超过限制丢弃的字节数
+func (r *RunLog) Dropped() int64 {
+	if r == nil {
+		return 0
+	}
+	r.Lock()
+	defer r.Unlock()
+	return r.dropped
+}
+
+func (r *RunLog) write(p []byte) (int, error) {
+	if r.mirror != nil {
+		r.partial = mirrorLines(r.mirror, r.jobId, r.logId, r.handler, r.partial, p)
+	}
+	if r.closed || r.w == nil {
+		// 没有缓存，或执行结束后任务启动的goroutine仍在写日志，直接写入存储
+		return storeWriter{r}.Write(p)
+	}
+	n, err := r.w.Write(p)
+	if r.timer == nil && r.w.Buffered() > 0 {
+		r.timer = time.AfterFunc(r.interval, r.timedFlush)
+	}
+	return n, err
+}
+
+func (r *RunLog) timedFlush() {
+	r.Lock()
+	defer r.Unlock()
+	r.timer = nil
+	if !r.closed {
+		r.w.Flush()
+	}
+}
+
+// Flush 缓存的日志写入存储
+func (r *RunLog) Flush() error {
+	if r == nil {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.closed || r.w == nil {
+		return nil
+	}
+	return r.w.Flush()
+}
+
+// Close 缓存写入存储并通知存储执行结束，之后的写入直接写入存储。
+// 丢弃的字节数不写入日志，由Dropped获取
+func (r *RunLog) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.closed {
+		return nil
+	}
+	if len(r.partial) > 0 {
+		r.mirror(r.jobId, r.logId, r.handler, string(r.partial))
+		r.partial = nil
+	}
+	r.closed = true
+	runLogs.CompareAndDelete(r.logId, r)
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	var err error
+	if r.w != nil {
+		err = r.w.Flush()
+	}
+	if ferr := r.store.Finish(r.logDateTime, r.logId); err == nil {
+		err = ferr
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func benchLogContext(logId int64) context.Context {
+	logParam := map[string]interface{}{
+		"logId":       logId,
+		"logDateTime": time.Now().UnixMilli(),
+		"jobId":       int32(1),
+		"jobName":     "bench",
+		"jobFunc":     "main.bench",
+	}
+	return context.WithValue(context.Background(), "jobParam", map[string]map[string]interface{}{"logParam": logParam})
+}
+
+func setupBench(b *testing.B, interval time.Duration) {
+	oldPath, oldStore, oldInterval := GetBasePath(), GetStore(), getFlushInterval()
+	SetBasePath(b.TempDir())
+	SetStore(&FileStore{})
+	SetFlushInterval(interval)
+	b.Cleanup(func() {
+		SetBasePath(oldPath)
+		SetStore(oldStore)
+		SetFlushInterval(oldInterval)
+	})
+}
+
+func TestRunLogTruncatedOnce(t *testing.T) {
+	s := NewMemoryStore()
+	useStore(t, s)
+	SetRunLogLimit(3, 0)
+	defer SetRunLogLimit(0, 0)
+
+	r := OpenRun(time.Now().UnixMilli(), 1, 1, "truncate")
+	for i := 0; i < 10; i++ {
+		r.Write([]byte("hello world\n"))
+	}
+	if dropped := r.Dropped(); dropped != 7*12 {
+		t.Fatalf("dropped %d, want %d", dropped, 7*12)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content := string(s.Bytes(1))
+	if n := strings.Count(content, "[TRUNCATED]"); n != 1 {
+		t.Fatalf("got %d truncated markers, want 1:\n%s", n, content)
+	}
+	if n := strings.Count(content, "hello world"); n != 3 {
+		t.Fatalf("got %d lines, want 3", n)
+	}
+}
+
+// BenchmarkLogOpenPerLine 没有打开RunLog，每行日志打开、写入、关闭文件
+func BenchmarkLogOpenPerLine(b *testing.B) {
+	setupBench(b, time.Second)
+	ctx := benchLogContext(1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Info(ctx, "hello world")
+	}
+}
+
+// BenchmarkRunLogBuffered RunLog缓存写入，定时刷盘
+func BenchmarkRunLogBuffered(b *testing.B) {
+	setupBench(b, time.Second)
+	r := OpenRun(0, 2, 1, "bench")
+	defer r.Close()
+	ctx := benchLogContext(2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Info(ctx, "hello world")
+	}
+}
+
+// BenchmarkRunLogUnbuffered flushInterval<=0，RunLog每行直接写入存储
+func BenchmarkRunLogUnbuffered(b *testing.B) {
+	setupBench(b, 0)
+	r := OpenRun(0, 3, 1, "bench")
+	defer r.Close()
+	ctx := benchLogContext(3)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Info(ctx, "hello world")
+	}
+}
+
+// BenchmarkRunLogBufferedParallel 任务启动的多个goroutine同时写入
+func BenchmarkRunLogBufferedParallel(b *testing.B) {
+	setupBench(b, time.Second)
+	r := OpenRun(0, 4, 1, "bench")
+	defer r.Close()
+	ctx := benchLogContext(4)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Info(ctx, "hello world")
+		}
+	})
+}
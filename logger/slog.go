@@ -0,0 +1,113 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// SlogHandler slog.Handler实现，任务上下文中的日志按xxl格式写入任务日志，其余日志交给fallback处理。
+// 使用方式：slog.SetDefault(slog.New(logger.NewSlogHandler(slog.Default().Handler())))，
+// 任务中调用slog.InfoContext(ctx, ...)即可写入任务日志
+type SlogHandler struct {
+	fallback slog.Handler
+	attrs    string //WithAttrs预先格式化的属性
+	group    string //WithGroup的分组前缀，如 "a.b."
+}
+
+// NewSlogHandler fallback处理非任务上下文的日志，为空时丢弃
+func NewSlogHandler(fallback slog.Handler) *SlogHandler {
+	return &SlogHandler{fallback: fallback}
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if InJob(ctx) {
+		return fromSlogLevel(level) >= getLevel()
+	}
+	return h.fallback != nil && h.fallback.Enabled(ctx, level)
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !InJob(ctx) {
+		if h.fallback == nil {
+			return nil
+		}
+		return h.fallback.Handle(ctx, r)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(r.Message)
+	sb.WriteString(h.attrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		appendAttr(&sb, h.group, attr)
+		return true
+	})
+	output(ctx, fromSlogLevel(r.Level), sb.String())
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	var sb strings.Builder
+	sb.WriteString(h.attrs)
+	for _, attr := range attrs {
+		appendAttr(&sb, h.group, attr)
+	}
+	h2 := *h
+	h2.attrs = sb.String()
+	if h.fallback != nil {
+		h2.fallback = h.fallback.WithAttrs(attrs)
+	}
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	if h.fallback != nil {
+		h2.fallback = h.fallback.WithGroup(name)
+	}
+	return &h2
+}
+
+// appendAttr 按 key=value 追加属性，分组属性展开为 group.key=value
+func appendAttr(sb *strings.Builder, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix = prefix + attr.Key + "."
+		}
+		for _, a := range attr.Value.Group() {
+			appendAttr(sb, prefix, a)
+		}
+		return
+	}
+	sb.WriteString(" ")
+	sb.WriteString(prefix)
+	sb.WriteString(attr.Key)
+	sb.WriteString("=")
+	sb.WriteString(attr.Value.String())
+}
+
+// fromSlogLevel slog级别转换为任务日志级别
+func fromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return DebugLevel
+	case level < slog.LevelWarn:
+		return InfoLevel
+	case level < slog.LevelError:
+		return WarnLevel
+	}
+	return ErrorLevel
+}
@@ -0,0 +1,75 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger, ctx context.Context)
+		want []string
+	}{
+		{"message and attrs", func(l *slog.Logger, ctx context.Context) {
+			l.InfoContext(ctx, "hello", "k", 1)
+		}, []string{"INFO  hello k=1"}},
+		{"with attrs and group", func(l *slog.Logger, ctx context.Context) {
+			l.With("a", 1).WithGroup("g").With("b", 2).InfoContext(ctx, "m", "k", "v")
+		}, []string{"INFO  m a=1 g.b=2 g.k=v"}},
+		{"group attr", func(l *slog.Logger, ctx context.Context) {
+			l.WithGroup("g").InfoContext(ctx, "m", slog.Group("req", "id", 7), slog.Group("", "flat", true))
+		}, []string{"INFO  m g.req.id=7 g.flat=true"}},
+		{"levels", func(l *slog.Logger, ctx context.Context) {
+			l.DebugContext(ctx, "debug")
+			l.WarnContext(ctx, "warn")
+			l.Log(ctx, slog.LevelWarn+2, "warn+2")
+			l.ErrorContext(ctx, "error")
+			l.Log(ctx, slog.LevelError+4, "error+4")
+		}, []string{"WARN  warn", "WARN  warn+2", "ERROR  error", "ERROR  error+4"}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := useMemoryStore(t)
+			var fallback bytes.Buffer
+			l := slog.New(NewSlogHandler(slog.NewTextHandler(&fallback, nil)))
+			logId := int64(4510 + i)
+			tt.log(l, benchLogContext(logId))
+
+			if got := jobLogMessages(s, logId); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("job log %q, want %q", got, tt.want)
+			}
+			if fallback.Len() != 0 {
+				t.Fatalf("job log written to fallback: %s", fallback.String())
+			}
+		})
+	}
+}
+
+func TestSlogHandlerFallback(t *testing.T) {
+	s := useMemoryStore(t)
+	var fallback bytes.Buffer
+	l := slog.New(NewSlogHandler(slog.NewTextHandler(&fallback, nil)))
+	l.With("a", 1).WithGroup("g").InfoContext(context.Background(), "outside", "k", "v")
+	if got := fallback.String(); !strings.Contains(got, "msg=outside a=1 g.k=v") {
+		t.Fatalf("fallback output %q", got)
+	}
+	if logs, _ := s.List(time.Now()); len(logs) != 0 {
+		t.Fatalf("non job log written to job log: %v", logs)
+	}
+
+	// fallback为空时丢弃非任务日志
+	h := NewSlogHandler(nil)
+	if h.Enabled(context.Background(), slog.LevelError) {
+		t.Fatal("handler without fallback enabled outside job")
+	}
+	if !h.Enabled(benchLogContext(4520), slog.LevelInfo) || h.Enabled(benchLogContext(4520), slog.LevelDebug) {
+		t.Fatal("job log level not mapped to the min job log level")
+	}
+}
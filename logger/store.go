@@ -0,0 +1,271 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogStore 任务日志存储，每次调度的日志由logDateTime及logId确定
+type LogStore interface {
+	// Append 追加日志内容，可能同时被多个调度调用
+	Append(logDateTime, logId int64, p []byte) error
+
+	// Read 读取fromLineNum开始的日志，行号从1开始，最多返回maxLines行、maxBytes字节（小于等于0时不限制）。
+	// 返回最后一行的行号，没有读取到日志时为fromLineNum-1；eof为true时已读到日志末尾。
+	// 只返回完整的行
+	Read(logDateTime, logId int64, fromLineNum int32, maxLines, maxBytes int) (toLineNum int32, content string, eof bool)
+
+	// Finish 调度执行结束，之后一般不再写入
+	Finish(logDateTime, logId int64) error
+
+	// Delete 删除调度的日志
+	Delete(logDateTime, logId int64) error
+
+	// List 调度时间早于before的日志，按调度时间升序排列，Cleaner据此清理日志
+	List(before time.Time) ([]LogInfo, error)
+}
+
+// LogInfo 一次调度的日志信息
+type LogInfo struct {
+	LogDateTime int64 //调度时间，毫秒；FileStore为日期目录的零点
+	LogId       int64
+	Size        int64 //日志字节数
+}
+
+type storeHolder struct {
+	LogStore
+}
+
+var store atomic.Value
+
+func init() {
+	store.Store(storeHolder{&FileStore{}})
+}
+
+// SetStore 设置任务日志存储，为空时使用FileStore，需在执行器启动前设置
+func SetStore(s LogStore) {
+	if s == nil {
+		s = &FileStore{}
+	}
+	store.Store(storeHolder{s})
+}
+
+func GetStore() LogStore {
+	return store.Load().(storeHolder).LogStore
+}
+
+// FileStore 默认的文件存储，日志写入 {basePath}/{调度日期}/{logId}.log
+type FileStore struct {
+	//执行结束的日志gzip压缩为 {logId}.log.gz，读取时自动解压
+	Compress bool
+
+	//执行结束多久后压缩，由Cleaner定时压缩，小于等于0时执行结束后立即压缩，
+	//执行结束后仍有写入的日志由Cleaner再次压缩
+	CompressAfter time.Duration
+}
+
+func (s *FileStore) Append(logDateTime, logId int64, p []byte) error {
+	logFile := GetLogFile(logDateTime, logId)
+	// 与压缩互斥，压缩期间的写入等待压缩完成后写入新文件
+	mu := fileLock(logFile)
+	mu.Lock()
+	defer mu.Unlock()
+	return writeLog(filepath.Dir(logFile), filepath.Base(logFile), string(p))
+}
+
+func (s *FileStore) Read(logDateTime, logId int64, fromLineNum int32, maxLines, maxBytes int) (toLineNum int32, content string, eof bool) {
+	if fromLineNum < 1 {
+		fromLineNum = 1
+	}
+	fileName := GetLogFile(logDateTime, logId)
+	// 从索引中最近的行开始读取，避免每次从头扫描；压缩的日志与之后写入的日志按顺序读取，行号与压缩前相同
+	index := logIndexes.get(fileName)
+	file, err := openLog(fileName, index)
+	if err != nil {
+		return fromLineNum - 1, "", true
+	}
+	defer file.Close()
+
+	lineNum, offset := index.seek(fromLineNum)
+	if err = file.skip(offset); err != nil {
+		return fromLineNum - 1, "", true
+	}
+	return readLines(file, lineNum, offset, index, fromLineNum, maxLines, maxBytes)
+}
+
+// Finish 开启了压缩且CompressAfter小于等于0时，异步压缩日志
+func (s *FileStore) Finish(logDateTime, logId int64) error {
+	if !s.Compress || s.CompressAfter > 0 {
+		return nil
+	}
+	fileName := GetLogFile(logDateTime, logId)
+	go func() {
+		if err := compressLog(fileName); err != nil {
+			log.Printf("compress job log failed. path:%s,err:%v\n", fileName, err)
+		}
+	}()
+	return nil
+}
+
+func (s *FileStore) Delete(logDateTime, logId int64) error {
+	fileName := GetLogFile(logDateTime, logId)
+	logIndexes.remove(fileName)
+	for _, name := range []string{fileName, fileName + gzipSuffix} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// 日期目录为空时删除
+	os.Remove(filepath.Dir(fileName))
+	return nil
+}
+
+func (s *FileStore) List(before time.Time) ([]LogInfo, error) {
+	var logs []LogInfo
+	for _, dir := range logDirs() {
+		if !dir.date.Before(before) {
+			break
+		}
+		entries, err := os.ReadDir(dir.path)
+		if err != nil {
+			return nil, err
+		}
+		// 同一调度的 .log 及 .log.gz 合并为一条
+		sizes := make(map[int64]int64)
+		var ids []int64
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			logId, ok := parseLogId(entry.Name())
+			if !ok {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			if _, ok = sizes[logId]; !ok {
+				ids = append(ids, logId)
+			}
+			sizes[logId] += info.Size()
+		}
+		sort.Slice(ids, func(i, k int) bool { return ids[i] < ids[k] })
+		for _, logId := range ids {
+			logs = append(logs, LogInfo{LogDateTime: dir.date.UnixMilli(), LogId: logId, Size: sizes[logId]})
+		}
+	}
+	return logs, nil
+}
+
+// MemoryStore 内存存储，用于测试
+type MemoryStore struct {
+	sync.RWMutex
+	logs map[int64]*memoryLog
+}
+
+type memoryLog struct {
+	logDateTime int64
+	data        []byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{logs: make(map[int64]*memoryLog)}
+}
+
+func (s *MemoryStore) Append(logDateTime, logId int64, p []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	l, ok := s.logs[logId]
+	if !ok {
+		l = &memoryLog{logDateTime: logDateTime}
+		s.logs[logId] = l
+	}
+	l.data = append(l.data, p...)
+	return nil
+}
+
+func (s *MemoryStore) Read(_, logId int64, fromLineNum int32, maxLines, maxBytes int) (toLineNum int32, content string, eof bool) {
+	if fromLineNum < 1 {
+		fromLineNum = 1
+	}
+	data := s.Bytes(logId)
+	return readLines(bytes.NewReader(data), 0, 0, nil, fromLineNum, maxLines, maxBytes)
+}
+
+func (s *MemoryStore) Finish(_, _ int64) error {
+	return nil
+}
+
+func (s *MemoryStore) Delete(_, logId int64) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.logs, logId)
+	return nil
+}
+
+func (s *MemoryStore) List(before time.Time) ([]LogInfo, error) {
+	s.RLock()
+	defer s.RUnlock()
+	beforeTime := before.UnixMilli()
+	var logs []LogInfo
+	for logId, l := range s.logs {
+		if l.logDateTime < beforeTime {
+			logs = append(logs, LogInfo{LogDateTime: l.logDateTime, LogId: logId, Size: int64(len(l.data))})
+		}
+	}
+	sort.Slice(logs, func(i, k int) bool {
+		if logs[i].LogDateTime != logs[k].LogDateTime {
+			return logs[i].LogDateTime < logs[k].LogDateTime
+		}
+		return logs[i].LogId < logs[k].LogId
+	})
+	return logs, nil
+}
+
+// Bytes 调度的全部日志内容
+func (s *MemoryStore) Bytes(logId int64) []byte {
+	s.RLock()
+	defer s.RUnlock()
+	l, ok := s.logs[logId]
+	if !ok {
+		return nil
+	}
+	return append([]byte(nil), l.data...)
+}
+
+// readLines 从第lineNum行结束处（偏移量offset）开始按行读取，返回fromLineNum开始的日志，index不为空时记录行索引
+func readLines(r io.Reader, lineNum int32, offset int64, index *lineIndex, fromLineNum int32, maxLines, maxBytes int) (toLineNum int32, content string, eof bool) {
+	toLineNum = fromLineNum - 1
+	var buffer bytes.Buffer
+	lines := 0
+	rd := bufio.NewReader(r)
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return toLineNum, buffer.String(), true
+		}
+		lineNum++
+		offset += int64(len(line))
+		if index != nil {
+			index.add(lineNum, offset)
+		}
+		if lineNum < fromLineNum {
+			continue
+		}
+		if (maxLines > 0 && lines >= maxLines) || (maxBytes > 0 && lines > 0 && buffer.Len()+len(line) > maxBytes) {
+			return toLineNum, buffer.String(), false
+		}
+		buffer.WriteString(line)
+		toLineNum = lineNum
+		lines++
+	}
+}
@@ -1,6 +1,14 @@
 package option
 
-import "time"
+import (
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/gongshen/xxl-job-client/constants"
+	"github.com/gongshen/xxl-job-client/logger"
+	"github.com/gongshen/xxl-job-client/queue"
+)
 
 const (
 	defaultAdminAddr = "http://localhost:8080/xxl-job-admin/"
@@ -8,6 +16,14 @@ const (
 	defaultPort      = 8081
 	defaultTimeout   = 5 * time.Second
 	defaultBeatTime  = 20 * time.Second
+
+	defaultJobIdleTimeout = 30 * time.Minute
+	defaultQueueCapacity  = 1000
+	defaultDedupWindow    = 10000
+
+	defaultLogReadMaxLines = 1000
+	defaultLogReadMaxBytes = 1024 * 1024
+	defaultLogFlushTime    = time.Second
 )
 
 type Option func(*ClientOptions)
@@ -31,7 +47,77 @@ type ClientOptions struct {
 	//执行器续约时间（超过30秒不续约admin会移除执行器，请设置到30秒以内）
 	BeatTime time.Duration
 
-	LogLevel int
+	//任务日志最低级别
+	LogLevel logger.Level
+
+	//统计任务执行中ERROR级别日志数量，执行成功但有ERROR日志时回调信息带上数量
+	CountErrorLogs bool
+
+	//任务日志目录
+	LogDir string
+
+	//GLUE脚本存放目录，为空时为任务日志目录下的gluesource目录
+	GlueSourceDir string
+
+	//任务日志日期目录的时区
+	LogLocation *time.Location
+
+	//任务日志保留天数，小于等于0时不清理
+	LogRetentionDays int
+
+	//任务日志目录最大总字节数，小于等于0时不限制
+	LogMaxTotalSize int64
+
+	//admin每次读取任务日志的最大行数及字节数
+	LogReadMaxLines int
+	LogReadMaxBytes int
+
+	//任务日志存储，为空时使用文件存储
+	LogStore logger.LogStore
+
+	//文件存储时压缩执行结束的日志，LogCompressAfter小于等于0时执行结束后立即压缩
+	LogCompress      bool
+	LogCompressAfter time.Duration
+
+	//每次调度最多写入的日志行数及字节数，超过后的日志丢弃，小于等于0时不限制
+	LogMaxLinesPerRun int64
+	LogMaxBytesPerRun int64
+
+	//任务日志镜像输出，任务日志及GLUE脚本的输出同时按行输出，为空时不输出
+	LogMirror logger.MirrorFunc
+
+	//任务日志缓存刷盘间隔，小于等于0时不缓存，每条日志直接写入文件
+	LogFlushInterval time.Duration
+
+	//任务线程空闲回收时间，小于等于0时不回收
+	JobIdleTimeout time.Duration
+
+	//执行器退出时等待任务线程结束（执行Destroy）的最长时间，小于等于0时为10秒
+	JobExitTimeout time.Duration
+
+	//任务队列默认容量及队列已满时的处理策略
+	QueueCapacity  int
+	OverflowPolicy queue.OverflowPolicy
+
+	//调度LogId去重窗口大小，小于等于0时不去重
+	DedupWindow int
+
+	//调度LogId持久化文件，为空时只保存在内存
+	DedupFile string
+
+	//执行器同时执行任务的最大数量，小于等于0时不限制
+	MaxConcurrency int
+
+	//不使用默认任务拦截器（记录失败日志及捕获panic）
+	DisableDefaultMiddleware bool
+
+	//任务panic时写入任务日志的堆栈最大字节数
+	MaxStackSize int
+
+	//不为空时任务panic的堆栈同时输出到应用日志
+	PanicLogger interface {
+		Printf(format string, v ...interface{})
+	}
 }
 
 func NewClientOptions(opts ...Option) ClientOptions {
@@ -42,10 +128,24 @@ func NewClientOptions(opts ...Option) ClientOptions {
 		Port:        defaultPort,
 		Timeout:     defaultTimeout,
 		BeatTime:    defaultBeatTime,
+
+		JobIdleTimeout: defaultJobIdleTimeout,
+		QueueCapacity:  defaultQueueCapacity,
+		OverflowPolicy: queue.Reject,
+		DedupWindow:    defaultDedupWindow,
+		LogDir:         constants.BasePath,
+		LogLocation:    time.Local,
+
+		LogReadMaxLines:  defaultLogReadMaxLines,
+		LogReadMaxBytes:  defaultLogReadMaxBytes,
+		LogFlushInterval: defaultLogFlushTime,
 	}
 	for _, o := range opts {
 		o(&options)
 	}
+	if options.GlueSourceDir == "" {
+		options.GlueSourceDir = filepath.Join(options.LogDir, "gluesource")
+	}
 	return options
 }
 
@@ -91,8 +191,152 @@ func WithBeatTime(beatTime time.Duration) Option {
 	}
 }
 
-func WithLogLevel(level int) Option {
+// job log dir, default /data/applogs/xxl-job/jobhandler/
+func WithLogDir(dir string) Option {
+	return func(o *ClientOptions) {
+		o.LogDir = dir
+	}
+}
+
+// glue source dir, default gluesource under the job log dir
+func WithGlueSourceDir(dir string) Option {
+	return func(o *ClientOptions) {
+		o.GlueSourceDir = dir
+	}
+}
+
+// time zone of the job log date dirs, default time.Local
+func WithLogLocation(loc *time.Location) Option {
+	return func(o *ClientOptions) {
+		o.LogLocation = loc
+	}
+}
+
+// job log retention days and max total bytes of the job log dir, expired logs and glue sources are removed
+func WithLogRetention(days int, maxTotalSize int64) Option {
+	return func(o *ClientOptions) {
+		o.LogRetentionDays = days
+		o.LogMaxTotalSize = maxTotalSize
+	}
+}
+
+// max lines and bytes of each job log read from admin, <=0 no limit
+func WithLogReadLimit(maxLines, maxBytes int) Option {
+	return func(o *ClientOptions) {
+		o.LogReadMaxLines = maxLines
+		o.LogReadMaxBytes = maxBytes
+	}
+}
+
+// job log storage, default logger.FileStore
+func WithLogStore(store logger.LogStore) Option {
+	return func(o *ClientOptions) {
+		o.LogStore = store
+	}
+}
+
+// gzip finished job logs of the default file store, after <=0 compress as soon as the job ends
+func WithLogCompress(after time.Duration) Option {
+	return func(o *ClientOptions) {
+		o.LogCompress = true
+		o.LogCompressAfter = after
+	}
+}
+
+// max lines and bytes each job run may write to its log, the rest is dropped, <=0 no limit
+func WithRunLogLimit(maxLines, maxBytes int64) Option {
+	return func(o *ClientOptions) {
+		o.LogMaxLinesPerRun = maxLines
+		o.LogMaxBytesPerRun = maxBytes
+	}
+}
+
+// tee every job log line to w, such as os.Stdout, tagged with jobId, logId and handler
+func WithLogMirror(w io.Writer) Option {
+	return func(o *ClientOptions) {
+		o.LogMirror = logger.MirrorTo(w)
+	}
+}
+
+// tee every job log line to fn, such as an application logger
+func WithLogMirrorFunc(fn logger.MirrorFunc) Option {
+	return func(o *ClientOptions) {
+		o.LogMirror = fn
+	}
+}
+
+// job log buffer flush interval, <=0 write each line directly
+func WithLogFlushInterval(interval time.Duration) Option {
+	return func(o *ClientOptions) {
+		o.LogFlushInterval = interval
+	}
+}
+
+// min job log level, default logger.InfoLevel
+func WithLogLevel(level logger.Level) Option {
 	return func(o *ClientOptions) {
 		o.LogLevel = level
 	}
 }
+
+// count the error logs of each run, the callback message says "completed with N errors" when the job succeeded
+func WithErrorLogCount() Option {
+	return func(o *ClientOptions) {
+		o.CountErrorLogs = true
+	}
+}
+
+// job thread idle timeout, idle job threads are removed after it, <=0 never remove
+func WithJobIdleTimeout(timeout time.Duration) Option {
+	return func(o *ClientOptions) {
+		o.JobIdleTimeout = timeout
+	}
+}
+
+// max time to wait for job threads to exit and run Destroy on ExitApplication, <=0 use 10s
+func WithJobExitTimeout(timeout time.Duration) Option {
+	return func(o *ClientOptions) {
+		o.JobExitTimeout = timeout
+	}
+}
+
+// default job queue capacity and the policy when the queue is full
+func WithJobQueue(capacity int, policy queue.OverflowPolicy) Option {
+	return func(o *ClientOptions) {
+		o.QueueCapacity = capacity
+		o.OverflowPolicy = policy
+	}
+}
+
+// deduplicate triggers by logId, remember the latest size logIds, persist them to file if file not empty
+func WithTriggerDedup(size int, file string) Option {
+	return func(o *ClientOptions) {
+		o.DedupWindow = size
+		o.DedupFile = file
+	}
+}
+
+// max number of jobs running at the same time in this executor, <=0 no limit
+func WithMaxConcurrency(limit int) Option {
+	return func(o *ClientOptions) {
+		o.MaxConcurrency = limit
+	}
+}
+
+// disable the default job middlewares which log the failed job and recover from panic,
+// a panic is still recovered by the job thread and reported as a failure
+func WithoutDefaultMiddleware() Option {
+	return func(o *ClientOptions) {
+		o.DisableDefaultMiddleware = true
+	}
+}
+
+// max bytes of the panic stack written to job log, and forward the stack to the application logger if not nil
+func WithPanicStack(maxSize int, logger interface {
+	Printf(format string, v ...interface{})
+}) Option {
+	return func(o *ClientOptions) {
+		o.MaxStackSize = maxSize
+		o.PanicLogger = logger
+	}
+}
@@ -1,71 +1,139 @@
 package queue
 
 import (
+	"context"
 	"errors"
-	"math"
 	"sync"
-	"sync/atomic"
 )
 
-type Node struct {
-	Item interface{}
-	Next *Node
+var (
+	ErrQueueFull   = errors.New("queue size exceeding maximum capacity")
+	ErrItemDropped = errors.New("queue is full, the newest item is dropped")
+)
+
+// OverflowPolicy 队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	Reject     OverflowPolicy = iota //拒绝放入，返回ErrQueueFull
+	DropOldest                       //丢弃队首最早的元素，放入新元素
+	DropNewest                       //丢弃新放入的元素，返回ErrItemDropped
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Reject:
+		return "REJECT"
+	case DropOldest:
+		return "DROP_OLDEST"
+	case DropNewest:
+		return "DROP_NEWEST"
+	}
+	return "UNKNOWN"
 }
 
-type Queue struct {
+// Queue 有界阻塞队列，支持多生产者多消费者
+type Queue[T any] struct {
 	sync.Mutex
-	Count    int32
-	Capacity int32
-	Head     *Node
-	Last     *Node
+	items    []T
+	capacity int
+	policy   OverflowPolicy
+	notEmpty chan struct{}
 }
 
-func NewQueue() *Queue {
-	node := &Node{}
-	return &Queue{
-		Count:    int32(0),
-		Capacity: math.MaxInt32,
-		Head:     node,
-		Last:     node,
+func NewQueue[T any](capacity int, policy OverflowPolicy) *Queue[T] {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &Queue[T]{
+		capacity: capacity,
+		policy:   policy,
+		notEmpty: make(chan struct{}, 1),
 	}
 }
 
-func (q *Queue) Put(item interface{}) error {
-	if item == nil {
-		return errors.New("item can't be nil")
+// Put 放入元素，队列已满时按溢出策略处理，DropOldest时返回被丢弃的元素
+func (q *Queue[T]) Put(item T) (dropped T, hasDropped bool, err error) {
+	q.Lock()
+	defer q.Unlock()
+	if len(q.items) >= q.capacity {
+		switch q.policy {
+		case DropOldest:
+			dropped, hasDropped = q.items[0], true
+			var zero T
+			q.items[0] = zero //help GC
+			q.items = q.items[1:]
+		case DropNewest:
+			return dropped, false, ErrItemDropped
+		default:
+			return dropped, false, ErrQueueFull
+		}
 	}
+	q.items = append(q.items, item)
+	q.signal()
+	return dropped, hasDropped, nil
+}
 
-	getPos := atomic.LoadInt32(&q.Count)
-	if getPos >= q.Capacity {
-		return errors.New("queue size exceeding maximum capacity")
+// Take 取出队首元素，队列为空时阻塞直到有元素或ctx结束
+func (q *Queue[T]) Take(ctx context.Context) (item T, err error) {
+	for {
+		if err = ctx.Err(); err != nil {
+			return item, err
+		}
+		q.Lock()
+		if len(q.items) > 0 {
+			item = q.items[0]
+			var zero T
+			q.items[0] = zero //help GC
+			q.items = q.items[1:]
+			if len(q.items) > 0 {
+				// 唤醒其他等待的消费者
+				q.signal()
+			}
+			q.Unlock()
+			return item, nil
+		}
+		q.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return item, ctx.Err()
+		case <-q.notEmpty:
+		}
 	}
+}
 
+// Drain 取出队列中所有元素
+func (q *Queue[T]) Drain() []T {
 	q.Lock()
 	defer q.Unlock()
-	node := &Node{Item: item}
-	q.Last.Next = node
-	q.Last = node
-	atomic.AddInt32(&q.Count, 1)
-	return nil
+	items := q.items
+	q.items = nil
+	return items
 }
 
-//保证单协程执行，不加锁
-func (q *Queue) Poll() (has bool, item interface{}) {
-	node := q.Head.Next
-	if node == nil {
-		return false, nil
-	}
-	res := node.Item
-	node.Item = nil //help GC
-	q.Head = node
-	atomic.AddInt32(&q.Count, -1)
-	return true, res
+// Snapshot 返回队列中所有元素的副本，不取出元素
+func (q *Queue[T]) Snapshot() []T {
+	q.Lock()
+	defer q.Unlock()
+	items := make([]T, len(q.items))
+	copy(items, q.items)
+	return items
 }
 
-func (q *Queue) Clear() {
-	q = NewQueue()
+func (q *Queue[T]) Len() int {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.items)
 }
 
-func (q *Queue) HasNext() bool {
-	return atomic.LoadInt32(&q.Count) > 0
+func (q *Queue[T]) Capacity() int {
+	return q.capacity
+}
+
+func (q *Queue[T]) signal() {
+	select {
+	case q.notEmpty <- struct{}{}:
+	default:
+	}
 }
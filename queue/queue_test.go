@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPutOverflow(t *testing.T) {
+	tests := []struct {
+		policy  OverflowPolicy
+		err     error
+		dropped int
+		items   []int
+	}{
+		{Reject, ErrQueueFull, 0, []int{1, 2}},
+		{DropNewest, ErrItemDropped, 0, []int{1, 2}},
+		{DropOldest, nil, 1, []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			q := NewQueue[int](2, tt.policy)
+			for i := 1; i <= 2; i++ {
+				if _, hasDropped, err := q.Put(i); err != nil || hasDropped {
+					t.Fatalf("put %d: hasDropped:%v,err:%v", i, hasDropped, err)
+				}
+			}
+			dropped, hasDropped, err := q.Put(3)
+			if err != tt.err {
+				t.Fatalf("put to full queue err %v, want %v", err, tt.err)
+			}
+			if hasDropped != (tt.dropped != 0) || dropped != tt.dropped {
+				t.Fatalf("dropped %d,%v, want %d", dropped, hasDropped, tt.dropped)
+			}
+			items := q.Snapshot()
+			if len(items) != len(tt.items) || items[0] != tt.items[0] || items[1] != tt.items[1] {
+				t.Fatalf("queue items %v, want %v", items, tt.items)
+			}
+		})
+	}
+}
+
+func TestTakeBlocksUntilPut(t *testing.T) {
+	q := NewQueue[int](1, Reject)
+	got := make(chan int)
+	go func() {
+		item, err := q.Take(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- item
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if _, _, err := q.Put(1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case item := <-got:
+		if item != 1 {
+			t.Fatalf("take %d, want 1", item)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("take not woken up by put")
+	}
+}
+
+func TestTakeCanceled(t *testing.T) {
+	q := NewQueue[int](1, Reject)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := q.Take(ctx)
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("take err %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("take not returned after cancel")
+	}
+
+	// 取消后放入的元素保留在队列中
+	q.Put(1)
+	if q.Len() != 1 {
+		t.Fatalf("queue len %d, want 1", q.Len())
+	}
+}
+
+func TestDrain(t *testing.T) {
+	q := NewQueue[int](3, Reject)
+	q.Put(1)
+	q.Put(2)
+	if items := q.Drain(); len(items) != 2 || q.Len() != 0 {
+		t.Fatalf("drain %v, remain %d", items, q.Len())
+	}
+}
@@ -7,11 +7,18 @@ import (
 	"github.com/gongshen/xxl-job-client/handler"
 	"github.com/gongshen/xxl-job-client/logger"
 	"github.com/gongshen/xxl-job-client/option"
+	"os"
+	"os/signal"
+	"time"
 )
 
 type XxlClient struct {
 	executor       *executor2.Executor
 	requestHandler *handler.RequestProcess
+	glueSourceDir  string
+	logCleaner     *logger.Cleaner
+	cleanInterval  time.Duration //定时清理间隔
+	initErr        error         //创建时的错误，Run时返回
 }
 
 func NewXxlClient(opts ...option.Option) *XxlClient {
@@ -33,17 +40,73 @@ func NewXxlClient(opts ...option.Option) *XxlClient {
 		"XXL-JOB-ACCESS-TOKEN": clientOps.AccessToken,
 	}
 
-	requestHandler = handler.NewRequestProcess(adminServer, &handler.HttpRequestHandler{})
+	requestHandler = handler.NewRequestProcess(adminServer, &handler.HttpRequestHandler{
+		LogMaxLines: clientOps.LogReadMaxLines,
+		LogMaxBytes: clientOps.LogReadMaxBytes,
+	})
+	logger.SetBasePath(clientOps.LogDir)
+	logger.SetLocation(clientOps.LogLocation)
+	logger.SetLevel(clientOps.LogLevel)
+	logger.SetFlushInterval(clientOps.LogFlushInterval)
+	logger.SetRunLogLimit(clientOps.LogMaxLinesPerRun, clientOps.LogMaxBytesPerRun)
+	logger.SetMirror(clientOps.LogMirror)
+	if clientOps.LogStore == nil && clientOps.LogCompress {
+		clientOps.LogStore = &logger.FileStore{
+			Compress:      true,
+			CompressAfter: clientOps.LogCompressAfter,
+		}
+	}
+	logger.SetStore(clientOps.LogStore)
+	requestHandler.JobHandler.CountErrorLogs = clientOps.CountErrorLogs
+	requestHandler.JobHandler.GlueSourcePath = clientOps.GlueSourceDir
+	requestHandler.JobHandler.IdleTimeout = clientOps.JobIdleTimeout
+	requestHandler.JobHandler.ExitTimeout = clientOps.JobExitTimeout
+	requestHandler.JobHandler.QueueCapacity = clientOps.QueueCapacity
+	requestHandler.JobHandler.OverflowPolicy = clientOps.OverflowPolicy
+	requestHandler.JobHandler.DisableDefaultMiddleware = clientOps.DisableDefaultMiddleware
+	requestHandler.JobHandler.Recover = handler.RecoverConfig{
+		MaxStackSize: clientOps.MaxStackSize,
+		Logger:       clientOps.PanicLogger,
+	}
+	if clientOps.MaxConcurrency > 0 {
+		requestHandler.JobHandler.WorkerPool = handler.NewSemaphore("executor", clientOps.MaxConcurrency)
+	}
+	var initErr error
+	if clientOps.DedupWindow > 0 {
+		requestHandler.Dedup, initErr = handler.NewTriggerDedup(clientOps.DedupWindow, clientOps.DedupFile)
+	}
 	httpServer := executor2.NewHttpServer(requestHandler.RequestProcess)
 	executor.SetServer(httpServer)
 
-	return &XxlClient{
+	client := &XxlClient{
 		requestHandler: requestHandler,
 		executor:       executor,
+		glueSourceDir:  clientOps.GlueSourceDir,
+		initErr:        initErr,
 	}
+	// 是否压缩以实际使用的存储为准，WithLogStore传入开启压缩的FileStore时同样需要定时压缩
+	fs, compress := logger.GetStore().(*logger.FileStore)
+	compress = compress && fs.Compress
+	if clientOps.LogRetentionDays > 0 || clientOps.LogMaxTotalSize > 0 || compress {
+		client.cleanInterval = time.Hour
+		if compress && fs.CompressAfter > 0 && fs.CompressAfter < client.cleanInterval {
+			client.cleanInterval = fs.CompressAfter
+		}
+		client.logCleaner = &logger.Cleaner{
+			RetentionDays:     clientOps.LogRetentionDays,
+			MaxTotalSize:      clientOps.LogMaxTotalSize,
+			GlueSourcePath:    clientOps.GlueSourceDir,
+			ActiveLogIds:      requestHandler.JobHandler.ActiveLogIds,
+			ActiveGlueSources: requestHandler.JobHandler.ActiveGlueSources,
+		}
+	}
+	return client
 }
 
 func (c *XxlClient) ExitApplication() {
+	if c.logCleaner != nil {
+		c.logCleaner.Stop()
+	}
 	c.requestHandler.RemoveRegisterExecutor()
 }
 
@@ -80,8 +143,19 @@ func GetSharding(ctx context.Context) (shardingIdx, shardingTotal int32) {
 }
 
 func (c *XxlClient) Run() error {
+	if c.initErr != nil {
+		return c.initErr
+	}
+	if err := logger.InitLogPath(); err != nil {
+		return err
+	}
+	if err := logger.CheckDir(c.glueSourceDir); err != nil {
+		return err
+	}
 	c.requestHandler.RegisterExecutor()
-	logger.InitLogPath()
+	if c.logCleaner != nil {
+		go c.logCleaner.AutoClean(c.cleanInterval)
+	}
 	return c.executor.Run()
 }
 
@@ -92,6 +166,73 @@ func (c *XxlClient) Close() error {
 	return nil
 }
 
-func (c *XxlClient) RegisterJob(jobName string, function handler.JobHandlerFunc) {
-	c.requestHandler.RegisterJob(jobName, function)
+// Use 添加全局任务拦截器，在默认拦截器之后、任务拦截器之前执行
+func (c *XxlClient) Use(middlewares ...handler.JobMiddleware) {
+	c.requestHandler.Use(middlewares...)
+}
+
+// DefaultMiddlewares 按WithPanicStack配置构建的默认拦截器，WithoutDefaultMiddleware时可通过Use重新添加
+func (c *XxlClient) DefaultMiddlewares() []handler.JobMiddleware {
+	return c.requestHandler.DefaultMiddlewares()
+}
+
+// Drain 进入摘流模式：idleBeat返回忙碌，拒绝新的调度，正在执行的任务继续执行。deregister为true时同时移除admin注册
+func (c *XxlClient) Drain(deregister bool) {
+	c.requestHandler.Drain(deregister)
+}
+
+// Undrain 退出摘流模式，摘流时移除了admin注册的重新注册
+func (c *XxlClient) Undrain() {
+	c.requestHandler.Undrain()
+}
+
+func (c *XxlClient) IsDraining() bool {
+	return c.requestHandler.IsDraining()
+}
+
+// DrainOnSignal 收到信号时切换摘流模式，例如 DrainOnSignal(true, syscall.SIGUSR1)
+func (c *XxlClient) DrainOnSignal(deregister bool, sig ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+	go func() {
+		for range ch {
+			if c.IsDraining() {
+				c.Undrain()
+			} else {
+				c.Drain(deregister)
+			}
+		}
+	}()
+}
+
+// AddIdleChecker 添加自定义idleBeat忙碌检查，例如CPU、内存或下游依赖不可用时返回忙碌
+func (c *XxlClient) AddIdleChecker(checker handler.IdleChecker) {
+	c.requestHandler.AddIdleChecker(checker)
+}
+
+// RegisterResource 注册共享资源，注册任务时通过handler.WithResources声明占用的资源
+func (c *XxlClient) RegisterResource(name string, limit int) {
+	c.requestHandler.RegisterResource(name, limit)
+}
+
+// RegisterJob 注册bean任务，job为func(ctx context.Context) error或handler.LifecycleJobHandler
+func (c *XxlClient) RegisterJob(jobName string, job interface{}, opts ...handler.JobOption) {
+	c.requestHandler.RegisterJob(jobName, job, opts...)
+}
+
+// UnregisterJob 注销bean任务，正在执行的任务继续执行，之后的调度返回任务不存在
+func (c *XxlClient) UnregisterJob(jobName string) error {
+	return c.requestHandler.UnregisterJob(jobName)
+}
+
+// ReplaceJob 替换已注册的bean任务，正在执行的任务继续使用旧的任务执行，之后的调度使用新的任务
+func (c *XxlClient) ReplaceJob(jobName string, job interface{}, opts ...handler.JobOption) error {
+	return c.requestHandler.ReplaceJob(jobName, job, opts...)
+}
+
+// RegisterJobsFrom 将obj的导出方法注册为bean任务，方法签名为 func(ctx context.Context) error 或 func(ctx context.Context)。
+// names为方法名到任务名的映射，值为空或"-"时跳过该方法，没有映射的方法以首字母小写的方法名注册，Init、Execute、Destroy需在names中指定。
+// 返回注册及跳过的方法，任务名重复时返回错误且不注册任何任务
+func (c *XxlClient) RegisterJobsFrom(obj interface{}, names map[string]string, opts ...handler.JobOption) (*handler.RegisterReport, error) {
+	return c.requestHandler.RegisterJobsFrom(obj, names, opts...)
 }
@@ -0,0 +1,44 @@
+package xxl
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gongshen/xxl-job-client/logger"
+	"github.com/gongshen/xxl-job-client/option"
+)
+
+func TestRunReturnsDedupError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "not-exist", "dedup")
+	client := NewXxlClient(option.WithTriggerDedup(10, file))
+	if err := client.Run(); err == nil {
+		t.Fatal("Run should return the trigger dedup error")
+	}
+}
+
+func TestLogCleanerFromStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []option.Option
+		interval time.Duration //为0时不创建Cleaner
+	}{
+		{"no clean", nil, 0},
+		{"retention", []option.Option{option.WithLogRetention(7, 0)}, time.Hour},
+		{"compress after", []option.Option{option.WithLogCompress(10 * time.Minute)}, 10 * time.Minute},
+		{"compress immediately", []option.Option{option.WithLogCompress(0)}, time.Hour},
+		{"compress after a day", []option.Option{option.WithLogCompress(24 * time.Hour)}, time.Hour},
+		{"compressed store", []option.Option{option.WithLogStore(&logger.FileStore{Compress: true, CompressAfter: time.Minute})}, time.Minute},
+		{"uncompressed store", []option.Option{option.WithLogStore(&logger.FileStore{CompressAfter: time.Minute})}, 0},
+		{"memory store", []option.Option{option.WithLogStore(logger.NewMemoryStore())}, 0},
+	}
+	t.Cleanup(func() { logger.SetStore(nil) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewXxlClient(tt.opts...)
+			if (client.logCleaner != nil) != (tt.interval > 0) || client.cleanInterval != tt.interval {
+				t.Fatalf("cleaner:%v,interval:%s, want interval %s", client.logCleaner != nil, client.cleanInterval, tt.interval)
+			}
+		})
+	}
+}